@@ -4,20 +4,29 @@
 package appctl
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"net/rpc"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
 )
 
+var logger = log.Module("appctl")
+
 type appctlDaemon string
 
 const (
@@ -27,6 +36,15 @@ const (
 	ovsDbServer   appctlDaemon = "ovsdb-server"
 )
 
+// Targets of Call which are not resolved like the -t option of ovs-appctl.
+// The ovsdb-server target is the database server of ovs-vswitchd, unlike the
+// ovsDbServer daemon which serves the OVN databases.
+const (
+	ovsOvsdbServer appctlDaemon = "ovs-ovsdb-server"
+	ovnNbDb        appctlDaemon = "ovn-nb"
+	ovnSbDb        appctlDaemon = "ovn-sb"
+)
+
 func getPidFromFile(pidfile string) (int, error) {
 	f, err := os.Open(pidfile)
 	if err != nil {
@@ -55,10 +73,6 @@ func getPidFromCtlFiles(rundir string, daemon appctlDaemon) (int, error) {
 		return 0, err
 	}
 
-	if len(matches) == 0 {
-		return 0, fmt.Errorf("no control socket files found for %s", daemon)
-	}
-
 	// Extract PID from the first matching file
 	// Expected format: daemon.pid.ctl
 	re := regexp.MustCompile(fmt.Sprintf(`%s\.(\d+)\.ctl$`, regexp.QuoteMeta(string(daemon))))
@@ -73,123 +87,444 @@ func getPidFromCtlFiles(rundir string, daemon appctlDaemon) (int, error) {
 		}
 	}
 
-	return 0, fmt.Errorf("could not extract PID from control socket files for %s", daemon)
+	return 0, fmt.Errorf("%s: %w in %s", daemon, ErrSocketMissing, rundir)
 }
 
-func prepareCallDbServer(method string, rundir string, args ...string) (string, []string, error) {
-
-	const (
-		ovnsbDb       = "OVN_Southbound"
-		ovnnbDb       = "OVN_Northbound"
-		clusterStatus = "cluster/status"
-	)
+const (
+	ovnsbDb       = "OVN_Southbound"
+	ovnnbDb       = "OVN_Northbound"
+	clusterStatus = "cluster/status"
+)
 
+// Return the unixctl socket of the ovsdb-server and the name of the database
+// it serves.
+func dbServerSocket(rundir string) (string, string, error) {
 	// Check which socket file exists
 	sbSocket := filepath.Join(rundir, "ovnsb_db.ctl")
 	nbSocket := filepath.Join(rundir, "ovnnb_db.ctl")
 
-	var sockpath string
-	var dbName string
-
 	if _, err := os.Stat(sbSocket); err == nil {
-		sockpath = sbSocket
-		dbName = ovnsbDb
+		return sbSocket, ovnsbDb, nil
 	} else if _, err := os.Stat(nbSocket); err == nil {
-		sockpath = nbSocket
-		dbName = ovnnbDb
-	} else {
-		return "", args, fmt.Errorf("no control socket files found for the ovs db server")
+		return nbSocket, ovnnbDb, nil
 	}
 
-	if method == clusterStatus {
-		args = []string{dbName}
+	return "", "", fmt.Errorf("%s: %w in %s", ovsDbServer, ErrSocketMissing, rundir)
+}
+
+// Return the unixctl socket of one OVN database server.
+func dbSocket(rundir, name string, daemon appctlDaemon) (string, error) {
+	sockpath := filepath.Join(rundir, name)
+	if _, err := os.Stat(sockpath); err != nil {
+		return "", fmt.Errorf("%s: %w in %s", daemon, ErrSocketMissing, rundir)
 	}
+	return sockpath, nil
+}
+
+// Backend runs unixctl commands on the daemons of one OVS/OVN instance. The
+// commands are aborted when ctx is done.
+type Backend interface {
+	OvsVSwitchd(ctx context.Context, method string, args ...string) (string, error)
+	OvnController(ctx context.Context, method string, args ...string) (string, error)
+	OvnNorthd(ctx context.Context, method string, args ...string) (string, error)
+	OvsDbServer(ctx context.Context, method string, args ...string) (string, error)
+	// Call runs a command on any unixctl target of the instance. target
+	// is either the name of a daemon, as with the -t option of ovs-appctl,
+	// ovn-nb or ovn-sb for the OVN database servers, or the path of a
+	// unixctl socket. ovsdb-server is the database server of ovs-vswitchd.
+	Call(ctx context.Context, target, method string, args ...string) (string, error)
+	// Ping checks that a daemon can be reached. It returns the location of
+	// its socket, if known.
+	Ping(daemon string) (string, error)
+}
+
+// Client calls unixctl commands on the daemons of one OVS/OVN instance via
+// their unixctl sockets.
+type Client struct {
+	inst    *config.Instance
+	logger  *log.Logger
+	lock    sync.Mutex // protects daemons
+	daemons map[appctlDaemon]*daemonClient
+}
+
+// NewClient returns a client for the daemons of an instance.
+func NewClient(inst *config.Instance) *Client {
+	l := logger
+	if inst.Name != "" {
+		l = l.With("instance", inst.Name)
+	}
+	c := &Client{
+		inst:    inst,
+		logger:  l,
+		daemons: make(map[appctlDaemon]*daemonClient),
+	}
+	for _, d := range Daemons() {
+		c.daemons[appctlDaemon(d)] = c.newDaemonClient(appctlDaemon(d))
+	}
+	return c
+}
 
-	return sockpath, args, nil
+func (c *Client) newDaemonClient(daemon appctlDaemon) *daemonClient {
+	return &daemonClient{
+		client: c,
+		daemon: daemon,
+		logger: c.logger.With("daemon", daemon),
+		busy:   make(chan struct{}, 1),
+	}
 }
 
-func call(daemon appctlDaemon, method string, args ...string) string {
-	var rundir, sockpath string
-	var err error
+// Return the client of a daemon or of another unixctl target, creating it if
+// needed.
+func (c *Client) daemon(daemon appctlDaemon) *daemonClient {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	d, ok := c.daemons[daemon]
+	if !ok {
+		d = c.newDaemonClient(daemon)
+		c.daemons[daemon] = d
+	}
+	return d
+}
 
+func (c *Client) rundir(daemon appctlDaemon) string {
 	switch daemon {
 	case ovsVswitchd:
-		rundir = config.OvsRundir()
+		return c.inst.OvsRundir
 	case ovnController:
-		rundir = config.OvnRundir()
+		return c.inst.OvnRundir
 	case ovnNorthd:
-		rundir = config.OvnRundir()
+		return c.inst.OvnRundir
 	case ovsDbServer:
-		rundir = config.OvsdbRundir()
+		return c.inst.OvsdbRundir
 	default:
 		panic(fmt.Errorf("unknown daemon value: %v", daemon))
 	}
+}
+
+// Resolve the unixctl socket path of a daemon from its PID file or, if it is
+// missing or stale, from the existing control sockets.
+func (c *Client) socketPath(daemon appctlDaemon) (string, error) {
+	switch daemon {
+	case ovsDbServer:
+		sockpath, _, err := dbServerSocket(c.rundir(daemon))
+		return sockpath, err
+	case ovsVswitchd, ovnController, ovnNorthd:
+		return c.pidSocketPath(c.rundir(daemon), daemon)
+	case ovsOvsdbServer:
+		return c.pidSocketPath(c.inst.OvsRundir, ovsDbServer)
+	case ovnNbDb:
+		return dbSocket(c.inst.OvsdbRundir, "ovnnb_db.ctl", daemon)
+	case ovnSbDb:
+		return dbSocket(c.inst.OvsdbRundir, "ovnsb_db.ctl", daemon)
+	default:
+		return c.targetSocketPath(string(daemon))
+	}
+}
+
+// Resolve the unixctl socket path of another target, in the same way as the
+// -t option of ovs-appctl. Both the OVS and OVN run directories are searched.
+func (c *Client) targetSocketPath(target string) (string, error) {
+	if filepath.IsAbs(target) {
+		return target, nil
+	}
+
+	var errs []error
+	for _, rundir := range []string{c.inst.OvsRundir, c.inst.OvnRundir} {
+		// databases and some daemons have a socket without PID
+		sockpath := filepath.Join(rundir, target+".ctl")
+		if _, err := os.Stat(sockpath); err == nil {
+			return sockpath, nil
+		}
+		sockpath, err := c.pidSocketPath(rundir, appctlDaemon(target))
+		if err == nil {
+			return sockpath, nil
+		}
+		errs = append(errs, err)
+	}
+	for _, err := range errs {
+		if errors.Is(err, ErrStalePidfile) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%s: %w", target, ErrSocketMissing)
+}
+
+func (c *Client) pidSocketPath(rundir string, daemon appctlDaemon) (string, error) {
+	pidfile := filepath.Join(rundir, fmt.Sprintf("%s.pid", daemon))
 
-	if daemon == ovsDbServer {
-		sockpath, args, err = prepareCallDbServer(method, rundir, args...)
+	// First try to get PID from .pid file
+	pid, err := getPidFromFile(pidfile)
+	if err == nil {
+		sockpath := filepath.Join(rundir, fmt.Sprintf("%s.%d.ctl", daemon, pid))
+		if _, err := os.Stat(sockpath); err == nil {
+			return sockpath, nil
+		}
+		// The daemon may have been restarted without rewriting its
+		// PID file. Its control socket has the new PID.
+		stale := pid
+		pid, err = getPidFromCtlFiles(rundir, daemon)
 		if err != nil {
-			log.Errf("Failed to prepare call to %s: %s", daemon, err)
-			return ""
+			return "", fmt.Errorf("%s: %w: %s (pid %d)", daemon, ErrStalePidfile, pidfile, stale)
 		}
 	} else {
-		pidfile := filepath.Join(rundir, fmt.Sprintf("%s.pid", daemon))
-
-		// First try to get PID from .pid file
-		pid, err := getPidFromFile(pidfile)
+		c.logger.With("daemon", daemon, "error", err).Debugf(
+			"Failed to read PID file %s, trying to find PID from .ctl files", pidfile)
+		// If that fails, try to extract PID from .ctl files
+		pid, err = getPidFromCtlFiles(rundir, daemon)
 		if err != nil {
-			log.Debugf("Failed to read PID file %s: %s, trying to find PID from .ctl files", pidfile, err)
-			// If that fails, try to extract PID from .ctl files
-			pid, err = getPidFromCtlFiles(rundir, daemon)
-			if err != nil {
-				log.Errf("Failed to get PID for %s: %s", daemon, err)
-				return ""
-			}
+			return "", err
 		}
+	}
+
+	return filepath.Join(rundir, fmt.Sprintf("%s.%d.ctl", daemon, pid)), nil
+}
 
-		sockpath = filepath.Join(rundir, fmt.Sprintf("%s.%d.ctl", daemon, pid))
+// Daemons returns the names of all the daemons which can be reached via
+// their unixctl socket.
+func Daemons() []string {
+	return []string{
+		string(ovsVswitchd), string(ovnController),
+		string(ovnNorthd), string(ovsDbServer),
 	}
+}
 
-	conn, err := net.Dial("unix", sockpath)
+// SocketPath returns the unixctl socket path of a daemon, resolved in the same
+// way as when calling one of its commands.
+func (c *Client) SocketPath(daemon string) (string, error) {
+	if !slices.Contains(Daemons(), daemon) {
+		return "", fmt.Errorf("unknown daemon: %q", daemon)
+	}
+	return c.socketPath(appctlDaemon(daemon))
+}
+
+// Ping resolves the unixctl socket of a daemon and checks that it accepts
+// connections.
+func (c *Client) Ping(daemon string) (string, error) {
+	socket, err := c.SocketPath(daemon)
+	if err != nil {
+		return socket, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.UnixctlSettings().ConnectTimeout)
+	defer cancel()
+	s, err := c.daemon(appctlDaemon(daemon)).connect(ctx, socket)
 	if err != nil {
-		log.Errf("net.Dial: %s", err)
-		return ""
+		return socket, err
 	}
+	return socket, s.close()
+}
 
-	client := rpc.NewClientWithCodec(NewClientCodec(conn))
-	defer func() {
-		err := client.Close()
-		if err != nil {
-			log.Warningf("close: %s", err)
+// A connection to the unixctl socket of a daemon.
+type session struct {
+	sockpath string
+	conn     net.Conn
+	rpc      *rpc.Client
+}
+
+func (s *session) close() error {
+	return s.rpc.Close()
+}
+
+// daemonClient runs the unixctl commands of one daemon. When connections are
+// reused, commands are serialized on a single connection.
+type daemonClient struct {
+	client *Client
+	daemon appctlDaemon
+	logger *log.Logger
+	// Held while using the reused session.
+	busy chan struct{}
+	// The reused session, if any.
+	session *session
+}
+
+func (d *daemonClient) timeoutError(ctx context.Context, method string) error {
+	err := ctx.Err()
+	if err == nil {
+		// the socket deadline expired right before the context
+		err = context.DeadlineExceeded
+	}
+	return fmt.Errorf("%s: %s: %w: %w", d.daemon, method, ErrTimeout, err)
+}
+
+func (d *daemonClient) connect(ctx context.Context, sockpath string) (*session, error) {
+	dialer := net.Dialer{Timeout: config.UnixctlSettings().ConnectTimeout}
+	conn, err := dialer.DialContext(ctx, "unix", sockpath)
+	switch {
+	case err == nil:
+		return &session{
+			sockpath: sockpath,
+			conn:     conn,
+			rpc:      rpc.NewClientWithCodec(NewClientCodec(conn)),
+		}, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("%s: %w: %s", d.daemon, ErrSocketMissing, sockpath)
+	case ctx.Err() != nil || os.IsTimeout(err):
+		return nil, d.timeoutError(ctx, "connect")
+	default:
+		return nil, fmt.Errorf("%s: %w", d.daemon, err)
+	}
+}
+
+// Run one command on a session. The session must not be reused after an error
+// which is not an RPCError.
+func (d *daemonClient) send(ctx context.Context, s *session, method string, args []string) (string, error) {
+	// the context always has a deadline, see call
+	deadline, _ := ctx.Deadline()
+	if err := s.conn.SetDeadline(deadline); err != nil {
+		return "", fmt.Errorf("%s: %w", d.daemon, err)
+	}
+	// unblock pending reads and writes when ctx is canceled
+	stop := context.AfterFunc(ctx, func() {
+		_ = s.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	var reply string
+	var serverErr rpc.ServerError
+
+	d.logger.With("socket", s.sockpath).Debugf("calling: %s %s", method, args)
+	err := s.rpc.Call(method, args, &reply)
+	switch {
+	case err == nil:
+		return reply, nil
+	case errors.As(err, &serverErr):
+		return "", &RPCError{Daemon: string(d.daemon), Method: method, Message: string(serverErr)}
+	case ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded):
+		return "", d.timeoutError(ctx, method)
+	default:
+		return "", fmt.Errorf("%s: %s: %w", d.daemon, method, err)
+	}
+}
+
+// Close the reused session left over after reuse-connections was disabled.
+// This is best effort, the session is closed on a later call if it is busy.
+func (d *daemonClient) dropSession() {
+	select {
+	case d.busy <- struct{}{}:
+		if d.session != nil {
+			_ = d.session.close()
+			d.session = nil
 		}
-	}()
+		<-d.busy
+	default:
+	}
+}
 
+func (d *daemonClient) call(ctx context.Context, method string, args []string) (string, error) {
+	settings := config.UnixctlSettings()
+	ctx, cancel := context.WithTimeout(ctx, settings.CallTimeout)
+	defer cancel()
+
+	sockpath, err := d.client.socketPath(d.daemon)
+	if err != nil {
+		return "", err
+	}
 	if args == nil {
 		args = make([]string, 0)
 	}
 
-	var reply string
+	if !settings.ReuseConnections {
+		d.dropSession()
+		s, err := d.connect(ctx, sockpath)
+		if err != nil {
+			return "", err
+		}
+		defer s.close()
+		return d.send(ctx, s, method, args)
+	}
 
-	log.Debugf("calling: %s %s", method, args)
-	if err = client.Call(method, args, &reply); err != nil {
-		log.Errf("call(%s): %s", method, err)
-		return ""
+	select {
+	case d.busy <- struct{}{}:
+	case <-ctx.Done():
+		return "", d.timeoutError(ctx, method)
+	}
+	defer func() { <-d.busy }()
+
+	reused := false
+	if d.session != nil {
+		if d.session.sockpath == sockpath {
+			reused = true
+		} else {
+			// the daemon was restarted with another PID
+			d.logger.Debugf("socket changed to %s, reconnecting", sockpath)
+			_ = d.session.close()
+			d.session = nil
+		}
 	}
 
-	return reply
+	for {
+		if d.session == nil {
+			if d.session, err = d.connect(ctx, sockpath); err != nil {
+				return "", err
+			}
+		}
+		reply, err := d.send(ctx, d.session, method, args)
+		var rpcErr *RPCError
+		if err == nil || errors.As(err, &rpcErr) {
+			return reply, err
+		}
+		// the connection is in an unknown state
+		_ = d.session.close()
+		d.session = nil
+		if !reused || errors.Is(err, ErrTimeout) {
+			return "", err
+		}
+		// the daemon may have closed the idle connection, try once
+		// more on a new one
+		d.logger.With("error", err).Debugf("reused connection failed, reconnecting")
+		reused = false
+	}
 }
 
-func OvsVSwitchd(method string, args ...string) string {
-	return call(ovsVswitchd, method, args...)
+func (c *Client) call(ctx context.Context, daemon appctlDaemon, method string, args ...string) (string, error) {
+	if daemon == ovsDbServer && method == clusterStatus && len(args) == 0 {
+		_, dbName, err := dbServerSocket(c.rundir(daemon))
+		if err != nil {
+			selfmetrics.BackendError(selfmetrics.Unixctl)
+			return "", err
+		}
+		args = []string{dbName}
+	}
+
+	reply, err := c.daemon(daemon).call(ctx, method, args)
+	if err != nil {
+		c.logger.With("daemon", daemon, "error", err).Debugf("call(%s)", method)
+		selfmetrics.BackendError(selfmetrics.Unixctl)
+	}
+	return reply, err
 }
 
-func OvnController(method string, args ...string) string {
-	return call(ovnController, method, args...)
+func (c *Client) OvsVSwitchd(ctx context.Context, method string, args ...string) (string, error) {
+	return c.call(ctx, ovsVswitchd, method, args...)
 }
 
-func OvnNorthd(method string, args ...string) string {
-	return call(ovnNorthd, method, args...)
+func (c *Client) OvnController(ctx context.Context, method string, args ...string) (string, error) {
+	return c.call(ctx, ovnController, method, args...)
 }
 
-func OvsDbServer(method string, args ...string) string {
-	return call(ovsDbServer, method, args...)
+func (c *Client) OvnNorthd(ctx context.Context, method string, args ...string) (string, error) {
+	return c.call(ctx, ovnNorthd, method, args...)
+}
+
+func (c *Client) OvsDbServer(ctx context.Context, method string, args ...string) (string, error) {
+	return c.call(ctx, ovsDbServer, method, args...)
+}
+
+func (c *Client) Call(ctx context.Context, target, method string, args ...string) (string, error) {
+	if target == "" {
+		return "", errors.New("empty unixctl target")
+	}
+	if target == string(ovsDbServer) {
+		target = string(ovsOvsdbServer)
+	}
+	return c.call(ctx, appctlDaemon(target), method, args...)
+}
+
+// TargetName returns the name of a unixctl target, without the directory and
+// extension of a socket path. The ovsdb-server target is named
+// ovs-ovsdb-server to tell it from the OVN database server.
+func TargetName(target string) string {
+	if target == string(ovsDbServer) {
+		return string(ovsOvsdbServer)
+	}
+	return strings.TrimSuffix(filepath.Base(target), ".ctl")
 }
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package appctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+)
+
+func newClient(t *testing.T) (*Client, string) {
+	rundir := t.TempDir()
+	return NewClient(&config.Instance{
+		OvsRundir:   rundir,
+		OvnRundir:   rundir,
+		OvsdbRundir: rundir,
+	}), rundir
+}
+
+func setConfig(t *testing.T, yaml string) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSocketMissing(t *testing.T) {
+	c, _ := newClient(t)
+
+	for _, daemon := range Daemons() {
+		_, err := c.call(context.Background(), appctlDaemon(daemon), "version")
+		if !errors.Is(err, ErrSocketMissing) {
+			t.Errorf("%s: unexpected error: %v", daemon, err)
+		}
+	}
+}
+
+func TestStalePidfile(t *testing.T) {
+	c, rundir := newClient(t)
+	err := os.WriteFile(filepath.Join(rundir, "ovs-vswitchd.pid"), []byte("4242\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.OvsVSwitchd(context.Background(), "version"); !errors.Is(err, ErrStalePidfile) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the daemon was restarted without rewriting its PID file
+	srv := appctltest.NewServerPid(t, rundir, "ovs-vswitchd", 4343)
+	srv.Reply("version", "ovs-vswitchd (Open vSwitch) 3.3.0\n")
+	if err := os.WriteFile(filepath.Join(rundir, "ovs-vswitchd.pid"), []byte("4242\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.OvsVSwitchd(context.Background(), "version"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRPCError(t *testing.T) {
+	c, rundir := newClient(t)
+	appctltest.NewServer(t, rundir, "ovn-northd")
+
+	_, err := c.OvnNorthd(context.Background(), "foo/bar")
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rpcErr.Daemon != "ovn-northd" || rpcErr.Method != "foo/bar" {
+		t.Fatalf("unexpected error: %#v", rpcErr)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	setConfig(t, "unixctl:\n  call-timeout: 100ms\n")
+	c, rundir := newClient(t)
+	srv := appctltest.NewServer(t, rundir, "ovs-vswitchd")
+	hang := make(chan struct{})
+	// registered after the server, runs before it is closed
+	t.Cleanup(func() { close(hang) })
+	srv.Handle("memory/show", func([]string) (string, error) {
+		<-hang
+		return "", nil
+	})
+
+	start := time.Now()
+	_, err := c.OvsVSwitchd(context.Background(), "memory/show")
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("call not aborted after %s", d)
+	}
+}
+
+func TestReuseConnections(t *testing.T) {
+	setConfig(t, "unixctl:\n  reuse-connections: true\n")
+	c, rundir := newClient(t)
+
+	srv := appctltest.NewServerPid(t, rundir, "ovs-vswitchd", 1000)
+	srv.Reply("version", "1000")
+	for range 3 {
+		if reply, err := c.OvsVSwitchd(context.Background(), "version"); err != nil || reply != "1000" {
+			t.Fatalf("unexpected reply: %q %v", reply, err)
+		}
+	}
+	if n := srv.Connections(); n != 1 {
+		t.Fatalf("expected 1 connection, got %d", n)
+	}
+
+	// an RPC error does not close the connection
+	if _, err := c.OvsVSwitchd(context.Background(), "foo/bar"); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := srv.Connections(); n != 1 {
+		t.Fatalf("expected 1 connection, got %d", n)
+	}
+
+	// the daemon is restarted with another PID
+	srv.Close()
+	srv = appctltest.NewServerPid(t, rundir, "ovs-vswitchd", 1001)
+	srv.Reply("version", "1001")
+	for range 3 {
+		if reply, err := c.OvsVSwitchd(context.Background(), "version"); err != nil || reply != "1001" {
+			t.Fatalf("unexpected reply: %q %v", reply, err)
+		}
+	}
+	if n := srv.Connections(); n != 1 {
+		t.Fatalf("expected 1 connection, got %d", n)
+	}
+
+	// the daemon is restarted with the same PID
+	srv.Close()
+	srv = appctltest.NewServerPid(t, rundir, "ovs-vswitchd", 1001)
+	srv.Reply("version", "1001")
+	if reply, err := c.OvsVSwitchd(context.Background(), "version"); err != nil || reply != "1001" {
+		t.Fatalf("unexpected reply: %q %v", reply, err)
+	}
+}
+
+func TestReuseConnectionsDisabled(t *testing.T) {
+	setConfig(t, "unixctl:\n  reuse-connections: true\n")
+	c, rundir := newClient(t)
+	srv := appctltest.NewServer(t, rundir, "ovs-vswitchd")
+	srv.Reply("version", "1")
+	if _, err := c.OvsVSwitchd(context.Background(), "version"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reused connection is dropped by concurrent calls after reload
+	setConfig(t, "unixctl:\n  reuse-connections: false\n")
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.OvsVSwitchd(context.Background(), "version"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCallTargets(t *testing.T) {
+	ovsRundir, ovsdbRundir := t.TempDir(), t.TempDir()
+	c := NewClient(&config.Instance{
+		OvsRundir:   ovsRundir,
+		OvnRundir:   t.TempDir(),
+		OvsdbRundir: ovsdbRundir,
+	})
+
+	pid := os.Getpid()
+	err := os.WriteFile(filepath.Join(ovsRundir, "ovsdb-server.pid"), []byte(strconv.Itoa(pid)+"\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ovs := appctltest.NewSocketServer(t, filepath.Join(ovsRundir, fmt.Sprintf("ovsdb-server.%d.ctl", pid)))
+	ovs.Reply("memory/show", "ovs")
+	nb := appctltest.NewSocketServer(t, filepath.Join(ovsdbRundir, "ovnnb_db.ctl"))
+	nb.Reply("memory/show", "nb")
+	sb := appctltest.NewServer(t, ovsdbRundir, "ovsdb-server")
+	sb.Reply("memory/show", "sb")
+
+	for target, expected := range map[string]string{
+		"ovsdb-server": "ovs",
+		"ovn-nb":       "nb",
+		"ovn-sb":       "sb",
+		nb.Socket:      "nb",
+	} {
+		reply, err := c.Call(context.Background(), target, "memory/show")
+		if err != nil || reply != expected {
+			t.Errorf("%s: unexpected reply: %q %v", target, reply, err)
+		}
+	}
+	// the OVN database server of the ovsdb-server collector
+	if reply, err := c.OvsDbServer(context.Background(), "memory/show"); err != nil || reply != "sb" {
+		t.Fatalf("unexpected reply: %q %v", reply, err)
+	}
+}
@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package appctltest provides a fake unixctl server for testing code which
+// calls commands on OVS/OVN daemons.
+package appctltest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Handler returns the reply of a unixctl command.
+type Handler func(args []string) (string, error)
+
+// Server is a fake unixctl server of one daemon. It listens on the same
+// socket path as the real daemon would in its run directory.
+type Server struct {
+	// Path of the unixctl socket.
+	Socket string
+	// PID written in the PID file of the daemon.
+	Pid int
+
+	listener net.Listener
+	lock     sync.Mutex
+	handlers map[string]Handler
+	calls    []Call
+	conns    map[net.Conn]struct{}
+	accepted int
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// Call is a command received by a Server.
+type Call struct {
+	Method string
+	Args   []string
+}
+
+// NewServer starts a fake unixctl server for daemon in rundir. For
+// ovs-vswitchd, ovn-controller and ovn-northd, a PID file is written along the
+// socket. For ovsdb-server, the socket of the OVN southbound database is used.
+// The server is stopped when the test completes.
+func NewServer(t testing.TB, rundir, daemon string) *Server {
+	t.Helper()
+	return NewServerPid(t, rundir, daemon, os.Getpid())
+}
+
+// NewServerPid is like NewServer but uses pid in the names of the PID file
+// and socket instead of the PID of the test process. It can be used to
+// simulate a daemon restart.
+func NewServerPid(t testing.TB, rundir, daemon string, pid int) *Server {
+	t.Helper()
+
+	var s *Server
+	if daemon == "ovsdb-server" {
+		s = NewSocketServer(t, filepath.Join(rundir, "ovnsb_db.ctl"))
+	} else {
+		pidfile := filepath.Join(rundir, daemon+".pid")
+		if err := os.WriteFile(pidfile, []byte(strconv.Itoa(pid)+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		s = NewSocketServer(t, filepath.Join(rundir, fmt.Sprintf("%s.%d.ctl", daemon, pid)))
+	}
+	s.Pid = pid
+	return s
+}
+
+// NewSocketServer starts a fake unixctl server listening on socket, without
+// PID file. The server is stopped when the test completes.
+func NewSocketServer(t testing.TB, socket string) *Server {
+	t.Helper()
+
+	s := &Server{
+		Socket:   socket,
+		handlers: make(map[string]Handler),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	l, err := net.Listen("unix", s.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.listener = l
+
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Handle registers a handler for a unixctl command.
+func (s *Server) Handle(method string, h Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.handlers[method] = h
+}
+
+// Reply registers a canned reply for a unixctl command.
+func (s *Server) Reply(method, reply string) {
+	s.Handle(method, func([]string) (string, error) {
+		return reply, nil
+	})
+}
+
+// Calls returns the commands received so far.
+func (s *Server) Calls() []Call {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Connections returns the number of connections accepted so far.
+func (s *Server) Connections() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.accepted
+}
+
+// Close stops the server, closes the open connections and removes its
+// socket.
+func (s *Server) Close() {
+	if s.listener.Close() != nil {
+		return
+	}
+	s.lock.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.accepted++
+		s.lock.Unlock()
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+type request struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	Id     uint64   `json:"id"`
+}
+
+type response struct {
+	Id     uint64  `json:"id"`
+	Result *string `json:"result"`
+	Error  *string `json:"error"`
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		conn.Close()
+	}()
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+
+		resp := response{Id: req.Id}
+		reply, err := s.call(req.Method, req.Params)
+		if err != nil {
+			msg := err.Error()
+			resp.Error = &msg
+		} else {
+			resp.Result = &reply
+		}
+		if err := enc.Encode(&resp); err != nil {
+			return
+		}
+	}
+}
+
+var errUnknownCommand = errors.New("unknown command")
+
+func (s *Server) call(method string, args []string) (string, error) {
+	s.lock.Lock()
+	s.calls = append(s.calls, Call{Method: method, Args: args})
+	h, ok := s.handlers[method]
+	s.lock.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("%q is not a valid command: %w", method, errUnknownCommand)
+	}
+	return h(args)
+}
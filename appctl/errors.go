@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package appctl
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// The unixctl socket of the daemon was not found. The daemon is
+	// probably not running.
+	ErrSocketMissing = errors.New("unixctl socket not found")
+	// The PID file of the daemon refers to a process which has no unixctl
+	// socket. The daemon probably exited without removing it.
+	ErrStalePidfile = errors.New("stale pid file")
+	// The daemon did not reply in time. Errors wrapping ErrTimeout also
+	// wrap context.DeadlineExceeded or context.Canceled.
+	ErrTimeout = errors.New("timeout")
+)
+
+// RPCError is the error reply of a daemon to a unixctl command. The daemon is
+// running but could not process the command, e.g. because it is unknown.
+type RPCError struct {
+	Daemon  string
+	Method  string
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Daemon, e.Method, e.Message)
+}
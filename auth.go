@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Build the server TLS configuration according to the client certificate
+// authentication settings.
+func tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	switch config.TlsClientAuth() {
+	case config.CLIENT_AUTH_NONE:
+		return conf, nil
+	case config.CLIENT_AUTH_REQUEST:
+		conf.ClientAuth = tls.RequestClientCert
+	case config.CLIENT_AUTH_REQUIRE:
+		conf.ClientAuth = tls.RequireAnyClientCert
+	case config.CLIENT_AUTH_VERIFY:
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	pool, err := loadCertPool(config.TlsClientCa())
+	if err != nil {
+		return nil, err
+	}
+	conf.ClientCAs = pool
+
+	return conf, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no valid PEM certificate found", path)
+	}
+	return pool, nil
+}
+
+// Verify the client certificate presented in a TLS connection against the
+// client CA and the allowed names. Return nil if no certificate is required
+// and none was presented. A certificate is always required when allowed names
+// are configured.
+func verifyClientCert(
+	state *tls.ConnectionState, mode config.ClientAuth, pool *x509.CertPool,
+) error {
+	if len(state.PeerCertificates) == 0 {
+		if mode == config.CLIENT_AUTH_REQUEST && len(config.TlsClientAllowed()) == 0 {
+			return nil
+		}
+		return fmt.Errorf("no client certificate")
+	}
+
+	cert := state.PeerCertificates[0]
+
+	if len(state.VerifiedChains) == 0 {
+		// request and require modes: the certificate has not been
+		// verified during the handshake
+		intermediates := x509.NewCertPool()
+		for _, c := range state.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         pool,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	allowed := config.TlsClientAllowed()
+	if len(allowed) == 0 {
+		return nil
+	}
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, name := range names {
+		if name != "" && slices.Contains(allowed, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("certificate subject %q not in allowed names", cert.Subject)
+}
+
+// Enforce client certificate authentication when enabled. It can be combined
+// with basicAuthHandler, in which case both must succeed.
+func clientCertHandler(handler http.Handler) (http.Handler, error) {
+	mode := config.TlsClientAuth()
+	if mode == config.CLIENT_AUTH_NONE {
+		return handler, nil
+	}
+
+	pool, err := loadCertPool(config.TlsClientCa())
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if err := verifyClientCert(r.TLS, mode, pool); err != nil {
+			log.Infof("%s: client certificate rejected: %s", r.RemoteAddr, err)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}), nil
+}
+
+// Hash compared against when the user does not exist so that unknown and
+// known users take the same time to be rejected.
+const dummyHash = "$2a$10$jLAXIkCFlFmr4LzkYKPmAOl/YxbnkMYXtB3.x4n4aZenNlMAQtaLW"
+
+// Cache of successful password verifications. Hashed passwords are slow to
+// verify on purpose, which would be paid on every scrape otherwise.
+var verified sync.Map
+
+// Check a password against a bcrypt hash ("$2a$", "$2b$" or "$2y$" prefix),
+// an argon2id hash in PHC string format ("$argon2id$" prefix) or a plain
+// text password. All comparisons are done in constant time.
+func checkPassword(password, expected string) bool {
+	key := sha256.Sum256([]byte(expected + "\x00" + password))
+	if _, ok := verified.Load(key); ok {
+		return true
+	}
+
+	var ok bool
+	switch {
+	case strings.HasPrefix(expected, "$2a$"),
+		strings.HasPrefix(expected, "$2b$"),
+		strings.HasPrefix(expected, "$2y$"):
+		ok = bcrypt.CompareHashAndPassword([]byte(expected), []byte(password)) == nil
+	case strings.HasPrefix(expected, "$argon2id$"):
+		ok = checkArgon2id(password, expected)
+	default:
+		ok = subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+	}
+	if ok {
+		verified.Store(key, struct{}{})
+	}
+	return ok
+}
+
+// Verify a password against an argon2id hash encoded as:
+//
+//	$argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+//
+// where salt and hash are encoded in unpadded base64.
+func checkArgon2id(password, encoded string) bool {
+	var version int
+	var memory, time uint32
+	var threads uint8
+
+	fields := strings.Split(encoded, "$")
+	if len(fields) != 6 {
+		return false
+	}
+	if _, err := fmt.Sscanf(fields[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	_, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil {
+		return false
+	}
+	computed := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(hash)))
+
+	return subtle.ConstantTimeCompare(hash, computed) == 1
+}
+
+func basicAuthHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user, password, pass string
+		var ok, authorized bool
+
+		users := config.AuthUsers()
+		if len(users) == 0 {
+			// authentication disabled
+			authorized = true
+		} else if user, pass, ok = r.BasicAuth(); ok {
+			if password, ok = users[user]; ok {
+				authorized = checkPassword(pass, password)
+			} else {
+				checkPassword(pass, dummyHash)
+			}
+		}
+		if authorized {
+			handler.ServeHTTP(w, r)
+		} else {
+			w.Header().Add("WWW-Authenticate", `Basic realm="ovs-node-exporter"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func argon2idHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	hash := argon2.IDKey([]byte(password), salt, 1, 8*1024, 1, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, 8*1024, 1, 1,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash))
+}
+
+func TestCheckPassword(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("s3cr3t"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"plain", "s3cr3t"},
+		{"bcrypt", string(bcryptHash)},
+		{"argon2id", argon2idHash("s3cr3t")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !checkPassword("s3cr3t", tt.expected) {
+				t.Errorf("valid password rejected")
+			}
+			// second time is served from the cache
+			if !checkPassword("s3cr3t", tt.expected) {
+				t.Errorf("valid password rejected from cache")
+			}
+			if checkPassword("wrong", tt.expected) {
+				t.Errorf("invalid password accepted")
+			}
+			if checkPassword("", tt.expected) {
+				t.Errorf("empty password accepted")
+			}
+		})
+	}
+}
+
+func TestCheckPasswordMalformedArgon2id(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2id$",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=18$m=8192,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=8192,t=1,p=1$!!!$aGFzaA",
+	} {
+		if checkPassword("s3cr3t", hash) {
+			t.Errorf("%q: password accepted", hash)
+		}
+	}
+}
+
+func TestVerifyClientCertMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	state := &tls.ConnectionState{}
+
+	tlsConf := "tls-cert: cert.pem\ntls-key: key.pem\n"
+	writeConfig(t, path, tlsConf+"tls-client-ca: ca.pem\ntls-client-auth: request\n")
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyClientCert(state, config.CLIENT_AUTH_REQUEST, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	writeConfig(t, path, tlsConf+"tls-client-ca: ca.pem\ntls-client-auth: request\n"+
+		"tls-client-allowed-names: [prometheus]\n")
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyClientCert(state, config.CLIENT_AUTH_REQUEST, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := verifyClientCert(state, config.CLIENT_AUTH_REQUIRE, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestClientAuthWithoutTLS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+
+	for _, yaml := range []string{
+		"tls-client-ca: ca.pem\n",
+		"tls-client-auth: require\ntls-client-ca: ca.pem\ntls-cert: cert.pem\n",
+		"tls-client-allowed-names: [prometheus]\n",
+	} {
+		writeConfig(t, path, yaml)
+		if err := config.Parse(); err == nil {
+			t.Errorf("expected error: %s", yaml)
+		}
+	}
+}
+
+func TestClientCertHandlerUnreadableCA(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	writeConfig(t, path, "tls-cert: cert.pem\ntls-key: key.pem\n"+
+		"tls-client-ca: "+filepath.Join(t.TempDir(), "missing.pem")+"\n")
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientCertHandler(handlerReplying("ok")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSecureMuxReadyzAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	writeConfig(t, path, "tls-cert: cert.pem\ntls-key: key.pem\n"+
+		"auth-users:\n  - name: prometheus\n    password: s3cr3t\n")
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	mux, err := newSecureMux(handlerReplying("metrics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path string
+		auth bool
+		code int
+	}{
+		{healthzPath, false, http.StatusOK},
+		{readyzPath, false, http.StatusUnauthorized},
+		{config.HttpPath(), false, http.StatusUnauthorized},
+		{config.HttpPath(), true, http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.auth {
+			req.SetBasicAuth("prometheus", "s3cr3t")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("%s auth=%v: got %d, expected %d", tc.path, tc.auth, rec.Code, tc.code)
+		}
+	}
+}
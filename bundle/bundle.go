@@ -0,0 +1,409 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package bundle creates support bundles with the raw outputs of the OVS/OVN
+// backends, as read by the collectors. They are meant to be attached to bug
+// reports when a metric looks wrong.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/jsimonetti/rtnetlink/v2"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/replay"
+)
+
+// Maximum time spent on one backend request.
+const backendTimeout = 5 * time.Second
+
+// An appctl command issued by the collectors.
+type command struct {
+	daemon string
+	method string
+	call   func(ctx context.Context, inst *instance.Instance, method string) (string, error)
+}
+
+func ovsVswitchd(method string) command {
+	return command{"ovs-vswitchd", method, func(ctx context.Context, inst *instance.Instance, method string) (string, error) {
+		return inst.Appctl.OvsVSwitchd(ctx, method)
+	}}
+}
+
+func ovnController(method string) command {
+	return command{"ovn-controller", method, func(ctx context.Context, inst *instance.Instance, method string) (string, error) {
+		return inst.Appctl.OvnController(ctx, method)
+	}}
+}
+
+func ovnNorthd(method string) command {
+	return command{"ovn-northd", method, func(ctx context.Context, inst *instance.Instance, method string) (string, error) {
+		return inst.Appctl.OvnNorthd(ctx, method)
+	}}
+}
+
+func ovsDbServer(method string) command {
+	return command{"ovsdb-server", method, func(ctx context.Context, inst *instance.Instance, method string) (string, error) {
+		return inst.Appctl.OvsDbServer(ctx, method)
+	}}
+}
+
+// The appctl commands issued by the collectors. Please keep in sync.
+var commands = []command{
+	ovsVswitchd("coverage/show"),
+	ovsVswitchd("dpctl/show"),
+	ovsVswitchd("dpif-netdev/pmd-perf-show"),
+	ovsVswitchd("dpif-netdev/pmd-rxq-show"),
+	ovsVswitchd("memory/show"),
+	ovnController("coverage/show"),
+	ovnNorthd("coverage/show"),
+	ovnNorthd("status"),
+	ovsDbServer("cluster/status"),
+}
+
+// The OVSDB tables read by the collectors.
+var tables = []string{
+	ovs.BridgeTable,
+	ovs.InterfaceTable,
+	ovs.OpenvSwitchTable,
+	ovs.PortTable,
+}
+
+type writer struct {
+	tar    *tar.Writer
+	now    time.Time
+	errors []string
+}
+
+func (w *writer) add(name string, data []byte) error {
+	err := w.tar.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: w.now,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.tar.Write(data)
+	return err
+}
+
+func (w *writer) addSymlink(name, target string) error {
+	return w.tar.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     name,
+		Linkname: target,
+		Mode:     0o777,
+		ModTime:  w.now,
+	})
+}
+
+func (w *writer) addJSON(name string, v any) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return w.add(name, append(buf, '\n'))
+}
+
+// Record an error which prevented some data from being captured. They are
+// stored in errors.txt so that a partial bundle is still useful.
+func (w *writer) failed(name string, err error) {
+	w.errors = append(w.errors, fmt.Sprintf("%s: %s", name, err))
+}
+
+func (w *writer) addFile(name, src string) error {
+	buf, err := os.ReadFile(src)
+	if err != nil {
+		w.failed(name, err)
+		return nil
+	}
+	return w.add(name, buf)
+}
+
+// Return the version of the exporter, its dependencies and the go toolchain
+// used to build it.
+func version() []byte {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return []byte("unknown\n")
+	}
+	return []byte(info.String())
+}
+
+// Path of the files of an instance in the bundle.
+func instancePath(inst *instance.Instance, name string) string {
+	if inst.Name != "" {
+		return path.Join("instances", replay.FileName(inst.Name), name)
+	}
+	return name
+}
+
+// Return the commands of the custom collector which are not already issued
+// by the other collectors. Their arguments are not part of the file names.
+func customCommands() []command {
+	seen := make(map[string]bool)
+	for _, c := range commands {
+		seen[path.Join(c.daemon, c.method)] = true
+	}
+
+	var res []command
+	for _, cc := range config.CustomCommands() {
+		daemon := appctl.TargetName(cc.Daemon)
+		if seen[path.Join(daemon, cc.Command)] {
+			continue
+		}
+		seen[path.Join(daemon, cc.Command)] = true
+		res = append(res, command{daemon, cc.Command,
+			func(ctx context.Context, inst *instance.Instance, method string) (string, error) {
+				return inst.Appctl.Call(ctx, cc.Daemon, method, cc.Args...)
+			}})
+	}
+	return res
+}
+
+func (w *writer) addCommands(inst *instance.Instance) error {
+	for _, c := range append(commands, customCommands()...) {
+		name := instancePath(inst, path.Join(c.daemon, c.method+".txt"))
+		ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+		reply, err := c.call(ctx, inst, c.method)
+		cancel()
+		if err != nil {
+			w.failed(name, err)
+			continue
+		}
+		if err := w.add(name, []byte(reply)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writer) addTables(inst *instance.Instance) error {
+	for _, table := range tables {
+		name := instancePath(inst, path.Join("ovsdb", table+".json"))
+		ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+		rows, err := inst.Ovsdb.Rows(ctx, table)
+		cancel()
+		if err != nil {
+			w.failed(name, err)
+			continue
+		}
+		if err := w.addJSON(name, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writer) addOpenflow(inst *instance.Instance) error {
+	var bridges []ovs.Bridge
+
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	err := ovsdb.List(ctx, inst.Ovsdb, &bridges)
+	cancel()
+	if err != nil {
+		w.failed(instancePath(inst, "openflow"), err)
+		return nil
+	}
+
+	for _, br := range bridges {
+		dir := instancePath(inst, path.Join("openflow", replay.FileName(br.Name)))
+		ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+		reply, err := inst.Openflow.AggregateReply(ctx, br.Name)
+		cancel()
+		if err != nil {
+			w.failed(path.Join(dir, "aggregate.bin"), err)
+			continue
+		}
+		if err := w.addReply(dir, "aggregate", reply, func(data []byte) (any, error) {
+			return openflow.DecodeAggregateReply(br.Name, data)
+		}); err != nil {
+			return err
+		}
+	}
+
+	dir := instancePath(inst, path.Join("openflow", replay.FileName(inst.IntBrdNam)))
+	ctx, cancel = context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	reply, err := inst.Openflow.RouterPortsReply(ctx)
+	if err != nil {
+		w.failed(path.Join(dir, "router-ports.bin"), err)
+		return nil
+	}
+	return w.addReply(dir, "router-ports", reply, func(data []byte) (any, error) {
+		return openflow.DecodeRouterPortsReply(data)
+	})
+}
+
+// Add a raw OpenFlow reply as <name>.bin, for replay, and its decoded form as
+// <name>.json, for humans.
+func (w *writer) addReply(dir, name string, reply []byte, decode func([]byte) (any, error)) error {
+	if err := w.add(path.Join(dir, name+".bin"), reply); err != nil {
+		return err
+	}
+	decoded, err := decode(reply)
+	if err != nil {
+		w.failed(path.Join(dir, name+".json"), err)
+		return nil
+	}
+	return w.addJSON(path.Join(dir, name+".json"), decoded)
+}
+
+// Add the interfaces of the host with their SR-IOV VF information. Only the
+// attributes used by the collectors are kept.
+func (w *writer) addLinks(inst *instance.Instance) error {
+	name := path.Join("netlink", "links.json")
+	links, err := inst.Netlink.Links()
+	if err != nil {
+		w.failed(name, err)
+		return nil
+	}
+	for i, l := range links {
+		if l.Attributes == nil {
+			continue
+		}
+		links[i].Attributes = &rtnetlink.LinkAttributes{
+			Name:       l.Attributes.Name,
+			Index:      l.Attributes.Index,
+			NumVF:      l.Attributes.NumVF,
+			VFInfoList: l.Attributes.VFInfoList,
+		}
+	}
+	if err := w.addJSON(name, links); err != nil {
+		return err
+	}
+	return w.addSysfs(inst.Netlink.SysfsRoot(), links)
+}
+
+// Add the sysfs files read by the netvf collector for the interfaces with
+// SR-IOV VFs: the link to the PCI device of the PF, its NUMA node and the
+// links to its VFs.
+func (w *writer) addSysfs(root string, links []rtnetlink.LinkMessage) error {
+	for _, l := range links {
+		if l.Attributes == nil || l.Attributes.NumVF == nil || *l.Attributes.NumVF == 0 {
+			continue
+		}
+
+		device := path.Join("class", "net", l.Attributes.Name, "device")
+		target, err := os.Readlink(filepath.Join(root, device))
+		if err != nil {
+			w.failed(path.Join("sys", device), err)
+			continue
+		}
+		if err := w.addSymlink(path.Join("sys", device), target); err != nil {
+			return err
+		}
+
+		pci := path.Join("bus", "pci", "devices", filepath.Base(target))
+		numa := path.Join(pci, "numa_node")
+		if err := w.addFile(path.Join("sys", numa), filepath.Join(root, numa)); err != nil {
+			return err
+		}
+		for _, vf := range l.Attributes.VFInfoList {
+			virtfn := path.Join(pci, fmt.Sprintf("virtfn%d", vf.ID))
+			target, err := os.Readlink(filepath.Join(root, virtfn))
+			if err != nil {
+				w.failed(path.Join("sys", virtfn), err)
+				continue
+			}
+			if err := w.addSymlink(path.Join("sys", virtfn), target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Add the ovs-vswitchd PID file and the status files of all its threads.
+func (w *writer) addTasks(inst *instance.Instance) error {
+	pidfile := filepath.Join(inst.OvsRundir, "ovs-vswitchd.pid")
+	name := instancePath(inst, path.Join("ovs-rundir", "ovs-vswitchd.pid"))
+	buf, err := os.ReadFile(pidfile)
+	if err != nil {
+		w.failed(name, err)
+		return nil
+	}
+	if err := w.add(name, buf); err != nil {
+		return err
+	}
+
+	pid := strings.TrimSpace(string(buf))
+	tasks := filepath.Join(inst.OvsProcdir, pid, "task")
+	entries, err := os.ReadDir(tasks)
+	if err != nil {
+		w.failed(instancePath(inst, path.Join("proc", pid, "task")), err)
+		return nil
+	}
+	for _, e := range entries {
+		name := instancePath(inst, path.Join("proc", pid, "task", e.Name(), "status"))
+		if err := w.addFile(name, filepath.Join(tasks, e.Name(), "status")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write a gzip compressed tarball with the outputs of all backends of all
+// instances, the effective configuration and the exporter version. Backends
+// which cannot be reached are reported in errors.txt.
+func Write(out io.Writer) error {
+	return write(out, instance.All())
+}
+
+func write(out io.Writer, instances []*instance.Instance) error {
+	gz := gzip.NewWriter(out)
+	w := &writer{tar: tar.NewWriter(gz), now: time.Now()}
+
+	if err := w.add("version.txt", version()); err != nil {
+		return err
+	}
+	var conf bytes.Buffer
+	if err := config.Dump(&conf); err != nil {
+		w.failed("config.yaml", err)
+	} else if err := w.add("config.yaml", conf.Bytes()); err != nil {
+		return err
+	}
+
+	if err := w.addLinks(instances[0]); err != nil {
+		return err
+	}
+	for _, inst := range instances {
+		for _, add := range []func(*instance.Instance) error{
+			w.addCommands, w.addTables, w.addOpenflow, w.addTasks,
+		} {
+			if err := add(inst); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(w.errors) > 0 {
+		errs := strings.Join(w.errors, "\n") + "\n"
+		if err := w.add("errors.txt", []byte(errs)); err != nil {
+			return err
+		}
+	}
+	if err := w.tar.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
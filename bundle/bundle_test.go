@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jsimonetti/rtnetlink/v2"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/bridge"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/memory"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/netvf"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/ovn"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow/openflowtest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovsdbtest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/replay"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+type fakeNetlink struct {
+	links []rtnetlink.LinkMessage
+	root  string
+}
+
+func (n *fakeNetlink) Links() ([]rtnetlink.LinkMessage, error) { return n.links, nil }
+func (n *fakeNetlink) SysfsRoot() string                       { return n.root }
+
+// Return an interface with one VF and its sysfs tree, as read by the netvf
+// collector.
+func fakeSriov(t *testing.T) *fakeNetlink {
+	t.Helper()
+	root := t.TempDir()
+
+	pci := filepath.Join(root, "bus", "pci", "devices", "0000:03:00.0")
+	netdev := filepath.Join(root, "class", "net", "enp3s0f0")
+	for _, dir := range []string{pci, netdev} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(pci, "numa_node"), []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../0000:03:01.0", filepath.Join(pci, "virtfn0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../bus/pci/devices/0000:03:00.0", filepath.Join(netdev, "device")); err != nil {
+		t.Fatal(err)
+	}
+
+	mac, _ := net.ParseMAC("52:54:00:ab:cd:ef")
+	numVF := uint32(1)
+	return &fakeNetlink{root: root, links: []rtnetlink.LinkMessage{{
+		Index: 4,
+		Attributes: &rtnetlink.LinkAttributes{
+			Name:  "enp3s0f0",
+			NumVF: &numVF,
+			VFInfoList: []rtnetlink.VFInfo{{
+				ID:    0,
+				MAC:   mac,
+				Vlan:  100,
+				Trust: true,
+				Stats: &rtnetlink.VFStats{RxPackets: 10, TxPackets: 20},
+			}},
+		},
+	}}}
+}
+
+func collect(t *testing.T, c prometheus.Collector) string {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	inst := instancetest.New(t)
+	db := ovsdbtest.NewServer(t, inst.OvsRundir)
+	db.Insert(t,
+		&ovs.OpenvSwitch{
+			Bridges:     []string{"brint"},
+			ExternalIDs: map[string]string{"ovn-encap-type": "geneve"},
+		},
+		&ovs.Bridge{UUID: "brint", Name: "br-int", DatapathType: "system", Ports: []string{"p1"}},
+		&ovs.Port{UUID: "p1", Name: "br-int", Interfaces: []string{"i1"}},
+		&ovs.Interface{UUID: "i1", Name: "br-int", Type: "internal"},
+	)
+	openflowtest.NewBridge(t, inst.OvsRundir, "br-int",
+		openflowtest.Flow{Table: 0, Packets: 10, Bytes: 1000},
+		openflowtest.RouterPortFlow(4, 2, 100, 6400),
+	)
+	vswitchd := appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+	vswitchd.Reply("memory/show", "handlers:29 ports:114 revalidators:11\n")
+	controller := appctltest.NewServer(t, inst.OvnRundir, "ovn-controller")
+	controller.Reply("coverage/show", "txn_error   0.0/sec   0.000/sec   0.0000/sec   total: 3\n")
+	inst.Netlink = fakeSriov(t)
+
+	var buf bytes.Buffer
+	if err := write(&buf, []*instance.Instance{inst}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := replay.Extract(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var decoded []openflow.RouterPortsStats
+	decodedBuf, err := os.ReadFile(filepath.Join(dir, "openflow", "br-int", "router-ports.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(decodedBuf, &decoded); err != nil || len(decoded) != 1 || decoded[0].PacketCount != 100 {
+		t.Fatalf("unexpected decoded reply: %s %v", decodedBuf, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "openflow", "br-int", "aggregate.json")); err != nil {
+		t.Fatal(err)
+	}
+	replayed := instance.NewReplay(&config.Instance{
+		OvsRundir:   filepath.Join(dir, "ovs-rundir"),
+		OvnRundir:   dir,
+		OvsdbRundir: dir,
+		OvsProcdir:  filepath.Join(dir, "proc"),
+		IntBrdNam:   "br-int",
+	}, dir, dir)
+
+	for _, c := range []struct {
+		name string
+		new  func(*instance.Instance) prometheus.Collector
+		want string
+	}{
+		{"bridge", func(i *instance.Instance) prometheus.Collector { return bridge.New(i) },
+			`ovs_bridge_flow_count{bridge="br-int",datapath_type="system"} 2`},
+		{"memory", func(i *instance.Instance) prometheus.Collector { return memory.New(i) },
+			`ovs_memory_ports_total 114`},
+		{"netvf", func(i *instance.Instance) prometheus.Collector { return netvf.New(i) },
+			`pci_address="0000:03:01.0",spoof_check="false",trust="true",vf="0",vlan="100"} 1`},
+		{"ovn", func(i *instance.Instance) prometheus.Collector { return ovn.New(i) },
+			`ovnc_router_port_traffic_pkts{datapath="4",port="2"} 100`},
+	} {
+		live := collect(t, c.new(inst))
+		if !strings.Contains(live, c.want) {
+			t.Fatalf("%s: %q not found in:\n%s", c.name, c.want, live)
+		}
+		if got := collect(t, c.new(replayed)); got != live {
+			t.Errorf("%s: replayed metrics differ:\n%s\nexpected:\n%s", c.name, got, live)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/filewatch"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+)
+
+// How often the TLS certificate and key files are checked for modifications.
+const certWatchInterval = 10 * time.Second
+
+// Serve a TLS certificate and key pair which is reloaded from disk when the
+// files change, e.g. when they are rotated by cert-manager.
+type certReloader struct {
+	certFile, keyFile string
+	cert              atomic.Pointer[tls.Certificate]
+}
+
+// Load the certificate and key pair and start watching the files for
+// changes. The initial load must succeed.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	go r.watch()
+	return r, nil
+}
+
+func (r *certReloader) watch() {
+	certInfo, _ := os.Stat(r.certFile)
+	keyInfo, _ := os.Stat(r.keyFile)
+	retry := false
+
+	for range time.Tick(certWatchInterval) {
+		newCertInfo, _ := os.Stat(r.certFile)
+		newKeyInfo, _ := os.Stat(r.keyFile)
+
+		// Files may be caught in the middle of a rotation. Retry until
+		// a valid pair is loaded.
+		if retry || filewatch.Changed(certInfo, newCertInfo) ||
+			filewatch.Changed(keyInfo, newKeyInfo) {
+			if err := r.load(); err != nil {
+				log.Errf("tls: keeping current certificate: %s", err)
+				retry = true
+			} else {
+				retry = false
+			}
+		}
+		certInfo, keyInfo = newCertInfo, newKeyInfo
+	}
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	cert.Leaf = leaf
+
+	if old := r.cert.Load(); old != nil {
+		log.Noticef("tls: loaded new certificate %q expiring on %s",
+			leaf.Subject, leaf.NotAfter.Format(time.RFC3339))
+	}
+	r.cert.Store(&cert)
+	selfmetrics.SetTLSCertExpiry(leaf.NotAfter)
+
+	return nil
+}
+
+// Implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+)
+
+var checkConfigFlag = flag.Bool("check-config", false,
+	"Validate the configuration, print the effective configuration and exit.\n"+
+		"The exit status is non-zero if the configuration is invalid.")
+
+// Verify that the collector and metric names referenced in the active
+// configuration exist.
+func checkNames() error {
+	var errs []error
+	var names, metrics []string
+
+	for _, c := range collectors.Collectors() {
+		names = append(names, c.Name())
+		for _, m := range c.Metrics() {
+			metrics = append(metrics, m.Name)
+		}
+	}
+	for option, refs := range config.CollectorReferences() {
+		for _, name := range refs {
+			if !slices.Contains(names, name) {
+				errs = append(errs, fmt.Errorf("%s: unknown collector: %q", option, name))
+			}
+		}
+	}
+	for option, refs := range map[string][]string{
+		"enable-metrics":  config.EnableMetrics(),
+		"disable-metrics": config.DisableMetrics(),
+	} {
+		for _, name := range refs {
+			if !slices.Contains(metrics, name) {
+				errs = append(errs, fmt.Errorf("%s: unknown metric: %q", option, name))
+			}
+		}
+	}
+	slices.SortFunc(errs, func(a, b error) int {
+		return strings.Compare(a.Error(), b.Error())
+	})
+
+	return errors.Join(errs...)
+}
+
+// Validate the configuration and print it on stdout. Errors are printed on
+// stderr. Return the process exit status.
+func checkConfig() int {
+	if err := config.ParseStrict(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 1
+	}
+
+	err := errors.Join(config.CheckPaths(), checkNames())
+
+	if dumpErr := config.Dump(os.Stdout); dumpErr != nil {
+		err = errors.Join(err, dumpErr)
+	}
+	if err != nil {
+		for _, e := range unwrapJoined(err) {
+			fmt.Fprintf(os.Stderr, "error: %s\n", e)
+		}
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "configuration is valid")
+	return 0
+}
+
+// Flatten errors created with errors.Join.
+func unwrapJoined(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var res []error
+	for _, e := range joined.Unwrap() {
+		res = append(res, unwrapJoined(e)...)
+	}
+	return res
+}
@@ -5,20 +5,34 @@ package bridge
 
 import (
 	"context"
-	"time"
+	"errors"
+	"fmt"
 
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Collector struct{}
+const collectorName = "bridge"
+
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "bridge"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -34,24 +48,38 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
 	var bridges []ovs.Bridge
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
 
-	err := ovsdb.List(ctx, &bridges)
+	err := ovsdb.List(ctx, c.inst.Ovsdb, &bridges)
 	if err != nil {
-		log.Errf("db.List(Bridge): %s", err)
-		return
+		return fmt.Errorf("db.List(Bridge): %w", err)
 	}
 
+	filters := config.Filters(c.Name())
+	var errs []error
+
 	for _, br := range bridges {
+		if !filters.Bridge.Match(br.Name) {
+			continue
+		}
 		labels := []string{br.Name, br.DatapathType}
 
 		for _, m := range metrics {
-			if config.MetricSets().Has(m.Set) {
-				ch <- prometheus.MustNewConstMetric(m.Desc(),
-					m.ValueType, m.GetValue(&br), labels...)
+			if !lib.MetricEnabled(collectorName, &m.Metric) {
+				continue
+			}
+			val, err := m.GetValue(ctx, c.inst, &br)
+			if err != nil {
+				errs = append(errs, err)
+				continue
 			}
+			ch <- prometheus.MustNewConstMetric(m.Desc(), m.ValueType, val, labels...)
 		}
 	}
+
+	return errors.Join(errs...)
 }
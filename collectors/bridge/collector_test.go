@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bridge
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow/openflowtest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovsdbtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	db := ovsdbtest.NewServer(t, inst.OvsRundir)
+	db.Insert(t,
+		&ovs.OpenvSwitch{Bridges: []string{"brint", "brex"}},
+		&ovs.Bridge{UUID: "brint", Name: "br-int", DatapathType: "netdev", Ports: []string{"p1", "p2"}},
+		&ovs.Bridge{UUID: "brex", Name: "br-ex", DatapathType: "system", Ports: []string{"p3"}},
+		&ovs.Port{UUID: "p1", Name: "br-int", Interfaces: []string{"i1"}},
+		&ovs.Port{UUID: "p2", Name: "vhu1", Interfaces: []string{"i2"}},
+		&ovs.Port{UUID: "p3", Name: "br-ex", Interfaces: []string{"i3"}},
+		&ovs.Interface{UUID: "i1", Name: "br-int", Type: "internal"},
+		&ovs.Interface{UUID: "i2", Name: "vhu1", Type: "dpdkvhostuserclient"},
+		&ovs.Interface{UUID: "i3", Name: "br-ex", Type: "internal"},
+	)
+	openflowtest.NewBridge(t, inst.OvsRundir, "br-int",
+		openflowtest.Flow{Table: 0, Packets: 10, Bytes: 1000},
+		openflowtest.RouterPortFlow(1, 2, 3, 300),
+	)
+	// no openflow endpoint for br-ex, its flow count is missing
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_bridge_flow_count The number of openflow rules configured on a bridge.
+# TYPE ovs_bridge_flow_count gauge
+ovs_bridge_flow_count{bridge="br-int",datapath_type="netdev"} 2
+# HELP ovs_bridge_port_count The number of ports in a bridge.
+# TYPE ovs_bridge_port_count gauge
+ovs_bridge_port_count{bridge="br-ex",datapath_type="system"} 1
+ovs_bridge_port_count{bridge="br-int",datapath_type="netdev"} 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the scrape is reported as failed
+	ch := make(chan prometheus.Metric, 10)
+	if err := New(inst).CollectWithContext(context.Background(), ch); err == nil {
+		t.Fatal("expected error")
+	}
+}
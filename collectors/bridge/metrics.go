@@ -4,17 +4,19 @@
 package bridge
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Metric struct {
 	lib.Metric
-	GetValue func(br *ovs.Bridge) float64
+	GetValue func(ctx context.Context, inst *instance.Instance, br *ovs.Bridge) (float64, error)
 }
 
 var labels = []string{"bridge", "datapath_type"}
@@ -28,8 +30,8 @@ var metrics = []Metric{
 			ValueType:   prometheus.GaugeValue,
 			Set:         config.METRICS_BASE,
 		},
-		func(br *ovs.Bridge) float64 {
-			return float64(len(br.Ports))
+		func(ctx context.Context, inst *instance.Instance, br *ovs.Bridge) (float64, error) {
+			return float64(len(br.Ports)), nil
 		},
 	},
 	{
@@ -40,14 +42,12 @@ var metrics = []Metric{
 			ValueType:   prometheus.GaugeValue,
 			Set:         config.METRICS_BASE,
 		},
-		func(br *ovs.Bridge) float64 {
-			bs := openflow.BridgeStats{Name: br.Name}
-			err := bs.GetAggregateStats()
+		func(ctx context.Context, inst *instance.Instance, br *ovs.Bridge) (float64, error) {
+			bs, err := inst.Openflow.AggregateStats(ctx, br.Name)
 			if err != nil {
-				log.Errf("bs.GetAggregateStats: %s", err)
-				return 0
+				return 0, fmt.Errorf("AggregateStats(%s): %w", br.Name, err)
 			}
-			return float64(bs.Flows)
+			return float64(bs.Flows), nil
 		},
 	},
 }
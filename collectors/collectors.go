@@ -6,6 +6,7 @@ package collectors
 import (
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/bridge"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/coverage"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/custom"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/datapath"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/iface"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
@@ -17,24 +18,67 @@ import (
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/pmd_perf"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/pmd_rxq"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/vswitch"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 )
 
 // All supported collectors. Please keep alpha sorted.
-var collectors = []lib.Collector{
-	new(bridge.Collector),
-	new(coverage.Collector),
-	new(datapath.Collector),
-	new(iface.Collector),
-	new(memory.Collector),
-	new(netvf.Collector),
-	new(ovnnorthd.Collector),
-	new(ovn.Collector),
-	new(ovsdbserver.Collector),
-	new(pmd_perf.Collector),
-	new(pmd_rxq.Collector),
-	new(vswitch.Collector),
+var constructors = []func(*instance.Instance) lib.Collector{
+	func(i *instance.Instance) lib.Collector { return bridge.New(i) },
+	func(i *instance.Instance) lib.Collector { return coverage.New(i) },
+	func(i *instance.Instance) lib.Collector { return custom.New(i) },
+	func(i *instance.Instance) lib.Collector { return datapath.New(i) },
+	func(i *instance.Instance) lib.Collector { return iface.New(i) },
+	func(i *instance.Instance) lib.Collector { return memory.New(i) },
+	func(i *instance.Instance) lib.Collector { return netvf.New(i) },
+	func(i *instance.Instance) lib.Collector { return ovnnorthd.New(i) },
+	func(i *instance.Instance) lib.Collector { return ovn.New(i) },
+	func(i *instance.Instance) lib.Collector { return ovsdbserver.New(i) },
+	func(i *instance.Instance) lib.Collector { return pmd_perf.New(i) },
+	func(i *instance.Instance) lib.Collector { return pmd_rxq.New(i) },
+	func(i *instance.Instance) lib.Collector { return vswitch.New(i) },
 }
 
+// Custom metrics cannot reuse the names of the metrics of the other
+// collectors.
+func init() {
+	for _, newCollector := range constructors {
+		c := newCollector(nil)
+		if _, ok := c.(*custom.Collector); ok {
+			continue
+		}
+		for _, m := range c.Metrics() {
+			config.ReserveMetricNames(m.Name)
+		}
+	}
+}
+
+// Collectors returns all supported collectors, bound to the first instance.
 func Collectors() []lib.Collector {
-	return collectors
+	return ForInstance(instance.All()[0])
+}
+
+// ForInstance returns all supported collectors bound to an instance. The
+// host wide collectors are only returned for the first instance so that
+// their metrics are not duplicated.
+func ForInstance(inst *instance.Instance) []lib.Collector {
+	var res []lib.Collector
+	for _, newCollector := range constructors {
+		c := newCollector(inst)
+		if h, ok := c.(interface{ HostWide() bool }); ok && h.HostWide() &&
+			inst != instance.All()[0] {
+			continue
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// AllInstances returns all supported collectors bound to each instance.
+func AllInstances() []lib.Collector {
+	var res []lib.Collector
+	for _, inst := range instance.All() {
+		res = append(res, ForInstance(inst)...)
+	}
+	return res
 }
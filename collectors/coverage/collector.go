@@ -5,28 +5,41 @@ package coverage
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const collectorName = "coverage"
+
 func makeMetric(m lib.Metric, val float64) prometheus.Metric {
-	if !config.MetricSets().Has(m.Set) {
+	if !lib.MetricEnabled(collectorName, &m) {
 		return nil
 	}
 	return prometheus.MustNewConstMetric(m.Desc(), m.ValueType, val)
 }
 
-type Collector struct{}
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "coverage"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -44,8 +57,17 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 // "netdev_sent       967178.4/sec 966510.667/sec   880482.1181/sec   total: 21235468562413"
 var coverageRe = regexp.MustCompile(`^(\w+)\s+.*\s+total: (\d+)$`)
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
-	buf := appctl.OvsVSwitchd("coverage/show")
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	buf, err := c.inst.Appctl.OvsVSwitchd(ctx, "coverage/show")
+	if err != nil {
+		return fmt.Errorf("coverage/show: %w", err)
+	}
+
+	logger := lib.Logger(c)
 
 	// Parse coverage/show output into a map of name -> value
 	// OVS only reports non-zero counters, so we need to emit 0 for missing ones
@@ -58,7 +80,7 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 			name := match[1]
 			val, err := strconv.ParseFloat(match[2], 64)
 			if err != nil {
-				log.Errf("%s: %s: %s", name, match[2], err)
+				logger.With("error", err).Errf("%s: %s", name, match[2])
 				continue
 			}
 			values[name] = val
@@ -73,4 +95,6 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 			ch <- metric
 		}
 	}
+
+	return nil
 }
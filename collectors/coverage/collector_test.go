@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package coverage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	vswitchd := appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+	vswitchd.Reply("coverage/show", `Event coverage, avg rate over last: 5 seconds, last minute, last hour,  hash=3c36f4ac:
+conntrack_full             0.0/sec     0.000/sec        0.0050/sec   total: 18
+netdev_sent               12.4/sec    10.183/sec        9.8811/sec   total: 367548
+42 events never hit
+`)
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_coverage_conntrack_full_total conntrack_full coverage counter
+# TYPE ovs_coverage_conntrack_full_total counter
+ovs_coverage_conntrack_full_total 18
+# HELP ovs_coverage_conntrack_l3csum_err_total conntrack_l3csum_err coverage counter
+# TYPE ovs_coverage_conntrack_l3csum_err_total counter
+ovs_coverage_conntrack_l3csum_err_total 0
+`), "ovs_coverage_conntrack_full_total", "ovs_coverage_conntrack_l3csum_err_total",
+		"ovs_coverage_netdev_sent_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorNoReply(t *testing.T) {
+	inst := instancetest.New(t)
+	appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+
+	if err := New(inst).CollectWithContext(context.Background(), nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
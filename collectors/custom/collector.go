@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package custom exports metrics parsed from the output of unixctl commands
+// listed in the custom-commands configuration setting.
+package custom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const collectorName = "custom"
+
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
+
+func (Collector) Name() string {
+	return collectorName
+}
+
+func newMetric(m *config.CustomMetric) lib.Metric {
+	valueType := prometheus.GaugeValue
+	if m.Type == "counter" {
+		valueType = prometheus.CounterValue
+	}
+	return lib.Metric{
+		Name:        m.Name,
+		Description: m.Help,
+		Labels:      m.LabelNames(),
+		ValueType:   valueType,
+		Set:         m.MetricSet(),
+	}
+}
+
+// Metrics returns the metrics of all custom commands. They are read from the
+// active configuration.
+func (Collector) Metrics() []lib.Metric {
+	var res []lib.Metric
+	for _, cmd := range config.CustomCommands() {
+		for i := range cmd.Metrics {
+			res = append(res, newMetric(&cmd.Metrics[i]))
+		}
+	}
+	return res
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	lib.DescribeEnabledMetrics(c, ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	var errs []error
+
+	commands := config.CustomCommands()
+	for i := range commands {
+		if err := c.collectCommand(ctx, &commands[i], ch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Collector) collectCommand(
+	ctx context.Context, cmd *config.CustomCommand, ch chan<- prometheus.Metric,
+) error {
+	var metrics []*config.CustomMetric
+	for i := range cmd.Metrics {
+		m := newMetric(&cmd.Metrics[i])
+		if lib.MetricEnabled(collectorName, &m) {
+			metrics = append(metrics, &cmd.Metrics[i])
+		}
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	buf, err := c.inst.Appctl.Call(ctx, cmd.Daemon, cmd.Command, cmd.Args...)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", cmd.Daemon, cmd.Command, err)
+	}
+	records := parse(cmd, buf)
+	logger := lib.Logger(c)
+
+	for _, cm := range metrics {
+		m := newMetric(cm)
+		// the same label values may be matched more than once
+		seen := make(map[string]bool)
+
+		for _, r := range records {
+			value, ok := r[cm.Value]
+			if !ok {
+				continue
+			}
+			val, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				logger.With("error", err).Debugf("%s: %s", cm.Name, value)
+				continue
+			}
+
+			labels := make([]string, 0, len(m.Labels))
+			for _, name := range m.Labels {
+				labels = append(labels, r[cm.Labels[name]])
+			}
+			key := strings.Join(labels, "\x00")
+			if seen[key] {
+				logger.Debugf("%s: duplicate labels %v", cm.Name, labels)
+				continue
+			}
+			seen[key] = true
+
+			ch <- prometheus.MustNewConstMetric(m.Desc(), m.ValueType, val, labels...)
+		}
+	}
+
+	return nil
+}
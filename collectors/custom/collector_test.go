@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package custom
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func setConfig(t *testing.T, yaml string) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	setConfig(t, `
+custom-commands:
+  - daemon: ovs-vswitchd
+    command: dpif-netdev/pmd-stats-show
+    args: [-pmd, "1"]
+    parse: regex
+    regex: '^pmd thread numa_id (?P<numa>\d+) core_id (?P<cpu>\d+):\n(?:\s+.*\n)*?\s+packets received: (?P<packets>\d+)'
+    metrics:
+      - name: ovs_custom_pmd_packets
+        type: counter
+        help: Packets received by a PMD thread.
+        value: packets
+        labels: {numa: numa, cpu: cpu}
+  - daemon: ovs-vswitchd
+    command: memory/show
+    parse: key-value
+    metrics:
+      - name: ovs_custom_memory_ports
+        value: ports
+      - name: ovs_custom_memory_missing
+        value: missing
+  - daemon: ovs-monitor-ipsec
+    command: coverage/show
+    parse: coverage
+    metrics:
+      - name: ovs_custom_ipsec_coverage_total
+        type: counter
+        value: total
+        labels: {event: name}
+`)
+	inst := instancetest.New(t)
+	vswitchd := appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+	vswitchd.Reply("dpif-netdev/pmd-stats-show", `pmd thread numa_id 0 core_id 3:
+  packets received: 42
+  packet recirculations: 0
+pmd thread numa_id 1 core_id 5:
+  packets received: 1337
+  packet recirculations: 1
+`)
+	vswitchd.Reply("memory/show", "handlers:29 ports:114 revalidators:11\n")
+	ipsec := appctltest.NewServer(t, inst.OvsRundir, "ovs-monitor-ipsec")
+	ipsec.Reply("coverage/show", `Event coverage, avg rate over last: 5 seconds, last minute, last hour,  hash=2b8bf06b:
+tunnel_add                 0.0/sec     0.000/sec        0.0000/sec   total: 3
+tunnel_del                 0.0/sec     0.000/sec        0.0000/sec   total: 1
+2 events never hit
+`)
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_custom_ipsec_coverage_total Value of total in the output of ovs-monitor-ipsec coverage/show.
+# TYPE ovs_custom_ipsec_coverage_total counter
+ovs_custom_ipsec_coverage_total{event="tunnel_add"} 3
+ovs_custom_ipsec_coverage_total{event="tunnel_del"} 1
+# HELP ovs_custom_memory_ports Value of ports in the output of ovs-vswitchd memory/show.
+# TYPE ovs_custom_memory_ports gauge
+ovs_custom_memory_ports 114
+# HELP ovs_custom_pmd_packets Packets received by a PMD thread.
+# TYPE ovs_custom_pmd_packets counter
+ovs_custom_pmd_packets{cpu="3",numa="0"} 42
+ovs_custom_pmd_packets{cpu="5",numa="1"} 1337
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, call := range vswitchd.Calls() {
+		if call.Method == "dpif-netdev/pmd-stats-show" && strings.Join(call.Args, " ") != "-pmd 1" {
+			t.Fatalf("unexpected args: %q", call.Args)
+		}
+	}
+}
+
+func TestCollectorNoDaemon(t *testing.T) {
+	setConfig(t, `
+custom-commands:
+  - daemon: ovn-controller
+    command: memory/show
+    parse: key-value
+    metrics:
+      - name: ovn_custom_memory_lflow_cache
+        value: lflow-cache-entries
+`)
+	err := New(instancetest.New(t)).CollectWithContext(context.Background(), nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	config.ReserveMetricNames("ovs_memory_ports_total")
+	for _, yaml := range []string{
+		"custom-commands: [{daemon: ovs-vswitchd, command: memory/show, parse: key-value, metrics: [{name: ovs_memory_ports_total, value: ports}]}]",
+		"custom-commands: [{daemon: ovs-vswitchd, command: memory/show, parse: foo, metrics: [{name: a, value: b}]}]",
+		"custom-commands: [{daemon: ovs-vswitchd, parse: key-value, metrics: [{name: a, value: b}]}]",
+		"custom-commands: [{daemon: ovs-vswitchd, command: memory/show, parse: regex, metrics: [{name: a, value: b}]}]",
+		"custom-commands: [{daemon: ovs-vswitchd, command: memory/show, parse: regex, regex: '(?P<x>\\d+)', metrics: [{name: a, value: b}]}]",
+		"custom-commands: [{daemon: ovs-vswitchd, command: memory/show, parse: coverage, metrics: [{name: a, value: total, labels: {event: foo}}]}]",
+		"custom-commands: [{daemon: ovs-vswitchd, command: memory/show, parse: key-value, metrics: [{name: a-b, value: b}]}]",
+		"custom-commands: [{daemon: ovs-vswitchd, command: memory/show, parse: key-value, metrics: [{name: a, value: b, type: summary}]}]",
+		"custom-commands: [{daemon: ovs-vswitchd, command: memory/show, parse: key-value, metrics: [{name: a, value: b}, {name: a, value: c}]}]",
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+		if err := config.Parse(); err == nil {
+			t.Errorf("expected error: %s", yaml)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package custom
+
+import (
+	"regexp"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+)
+
+// A parsed element of a command output, indexed by field name.
+type record map[string]string
+
+// "handlers:29 idl-cells-Open_vSwitch:7351 ports:114 revalidators:11"
+// "Status: active"
+var keyValueRe = regexp.MustCompile(`([\w./-]+):[ \t]*(\S+)`)
+
+// "netdev_sent       967178.4/sec 966510.667/sec   880482.1181/sec   total: 21235468562413"
+var coverageRe = regexp.MustCompile(`(?m)^(\w+)\s+.*\s+total: (\d+)$`)
+
+// Split the output of a command into records, according to its parser.
+func parse(cmd *config.CustomCommand, buf string) []record {
+	var records []record
+
+	switch cmd.Parse {
+	case config.CUSTOM_PARSE_REGEX:
+		re := cmd.Regexp()
+		for _, match := range re.FindAllStringSubmatch(buf, -1) {
+			r := make(record)
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					r[name] = match[i]
+				}
+			}
+			records = append(records, r)
+		}
+	case config.CUSTOM_PARSE_KEY_VALUE:
+		r := make(record)
+		for _, match := range keyValueRe.FindAllStringSubmatch(buf, -1) {
+			// keep the first occurrence of duplicate keys
+			if _, ok := r[match[1]]; !ok {
+				r[match[1]] = match[2]
+			}
+		}
+		records = append(records, r)
+	case config.CUSTOM_PARSE_COVERAGE:
+		for _, match := range coverageRe.FindAllStringSubmatch(buf, -1) {
+			records = append(records, record{"name": match[1], "total": match[2]})
+		}
+	}
+
+	return records
+}
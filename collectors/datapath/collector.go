@@ -5,20 +5,34 @@ package datapath
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Collector struct{}
+const collectorName = "datapath"
+
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "datapath"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -38,14 +52,18 @@ var (
 	flowsRe = regexp.MustCompile(`^  flows:\s*(\d+)$`)
 )
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
-	if !config.MetricSets().Has(config.METRICS_PERF) {
-		return
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	if !lib.AnyMetricEnabled(collectorName, c.Metrics()) {
+		return nil
 	}
 
-	buf := appctl.OvsVSwitchd("dpctl/show")
-	if buf == "" {
-		return
+	buf, err := c.inst.Appctl.OvsVSwitchd(ctx, "dpctl/show")
+	if err != nil {
+		return fmt.Errorf("dpctl/show: %w", err)
 	}
 
 	dptype := ""
@@ -58,23 +76,31 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 		if dptype != "" && dpname != "" {
 			if m := lookupsRe.FindStringSubmatch(line); m != nil {
 				val, _ := strconv.ParseFloat(m[1], 64)
-				ch <- prometheus.MustNewConstMetric(
-					hitsMetric.Desc(), hitsMetric.ValueType,
-					val, dptype, dpname)
+				if lib.MetricEnabled(collectorName, &hitsMetric) {
+					ch <- prometheus.MustNewConstMetric(
+						hitsMetric.Desc(), hitsMetric.ValueType,
+						val, dptype, dpname)
+				}
 				val, _ = strconv.ParseFloat(m[2], 64)
-				ch <- prometheus.MustNewConstMetric(
-					missedMetric.Desc(), missedMetric.ValueType,
-					val, dptype, dpname)
+				if lib.MetricEnabled(collectorName, &missedMetric) {
+					ch <- prometheus.MustNewConstMetric(
+						missedMetric.Desc(), missedMetric.ValueType,
+						val, dptype, dpname)
+				}
 				val, _ = strconv.ParseFloat(m[3], 64)
-				ch <- prometheus.MustNewConstMetric(
-					lostMetric.Desc(), lostMetric.ValueType,
-					val, dptype, dpname)
+				if lib.MetricEnabled(collectorName, &lostMetric) {
+					ch <- prometheus.MustNewConstMetric(
+						lostMetric.Desc(), lostMetric.ValueType,
+						val, dptype, dpname)
+				}
 				continue
 			} else if m := flowsRe.FindStringSubmatch(line); m != nil {
 				val, _ := strconv.ParseFloat(m[1], 64)
-				ch <- prometheus.MustNewConstMetric(
-					flowsMetric.Desc(), flowsMetric.ValueType,
-					val, dptype, dpname)
+				if lib.MetricEnabled(collectorName, &flowsMetric) {
+					ch <- prometheus.MustNewConstMetric(
+						flowsMetric.Desc(), flowsMetric.ValueType,
+						val, dptype, dpname)
+				}
 				continue
 			}
 		}
@@ -85,4 +111,6 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 			dpname = match[2]
 		}
 	}
+
+	return nil
 }
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package datapath
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	vswitchd := appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+	vswitchd.Reply("dpctl/show", `netdev@ovs-netdev:
+  lookups: hit:57723911358512 missed:132 lost:20
+  flows: 76
+  port 0: ovs-netdev (tap)
+  port 1: br-int (tap)
+`)
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_datapath_flows_total The number of datapath flows.
+# TYPE ovs_datapath_flows_total gauge
+ovs_datapath_flows_total{name="ovs-netdev",type="netdev"} 76
+# HELP ovs_datapath_lookup_hits_total The total number of lookups in the datapath flow cache.
+# TYPE ovs_datapath_lookup_hits_total gauge
+ovs_datapath_lookup_hits_total{name="ovs-netdev",type="netdev"} 5.7723911358512e+13
+# HELP ovs_datapath_lookup_lost_total Number of lost lookups in the datapath flow cache.
+# TYPE ovs_datapath_lookup_lost_total gauge
+ovs_datapath_lookup_lost_total{name="ovs-netdev",type="netdev"} 20
+# HELP ovs_datapath_lookup_missed_total Number of missed lookups in the datapath flow cache.
+# TYPE ovs_datapath_lookup_missed_total gauge
+ovs_datapath_lookup_missed_total{name="ovs-netdev",type="netdev"} 132
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,21 +5,34 @@ package iface
 
 import (
 	"context"
+	"fmt"
 	"strconv"
-	"time"
 
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Collector struct{}
+const collectorName = "interface"
+
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "interface"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -34,42 +47,48 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	lib.DescribeEnabledMetrics(c, ch)
 }
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
 	var bridges []ovs.Bridge
 	var ports []ovs.Port
 	var ifaces []ovs.Interface
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-
-	err := ovsdb.List(ctx, &bridges)
+	err := ovsdb.List(ctx, c.inst.Ovsdb, &bridges)
 	if err != nil {
-		log.Errf("db.List(Bridge): %s", err)
-		return
+		return fmt.Errorf("db.List(Bridge): %w", err)
 	}
-	err = ovsdb.List(ctx, &ports)
+	err = ovsdb.List(ctx, c.inst.Ovsdb, &ports)
 	if err != nil {
-		log.Errf("db.List(Port): %s", err)
-		return
+		return fmt.Errorf("db.List(Port): %w", err)
 	}
-	err = ovsdb.List(ctx, &ifaces)
+	err = ovsdb.List(ctx, c.inst.Ovsdb, &ifaces)
 	if err != nil {
-		log.Errf("db.List(Interface): %s", err)
-		return
+		return fmt.Errorf("db.List(Interface): %w", err)
 	}
 
+	filters := config.Filters(c.Name())
 	portBridge := make(map[string]string)
 	ifacePort := make(map[string]string)
 
 	for _, br := range bridges {
+		if !filters.Bridge.Match(br.Name) {
+			continue
+		}
 		for _, p := range br.Ports {
 			portBridge[p] = br.Name
 		}
 	}
 	for _, p := range ports {
+		bridge, ok := portBridge[p.UUID]
+		if !ok || !filters.Port.Match(p.Name) {
+			continue
+		}
 		for _, i := range p.Interfaces {
 			ifacePort[i] = p.Name
-			portBridge[ifacePort[i]] = portBridge[p.UUID]
+			portBridge[ifacePort[i]] = bridge
 		}
 	}
 	for _, i := range ifaces {
@@ -85,10 +104,13 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 			// empty string is a synonym for "system"
 			i.Type = "system"
 		}
+		if !filters.Interface.Match(i.Name) || !filters.InterfaceType.Match(i.Type) {
+			continue
+		}
 		labels := []string{bridge, port, i.Name, i.Type}
 
 		for _, m := range metrics {
-			if config.MetricSets().Has(m.Set) {
+			if lib.MetricEnabled(collectorName, &m.Metric) {
 				if m.GetValueLabel != nil {
 					for index := 0; ; index++ {
 						if value, ok := m.GetValueLabel(&i, index); ok {
@@ -105,4 +127,6 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 			}
 		}
 	}
+
+	return nil
 }
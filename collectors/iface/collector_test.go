@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package iface
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovsdbtest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	db := ovsdbtest.NewServer(t, inst.OvsRundir)
+	db.Insert(t,
+		&ovs.OpenvSwitch{Bridges: []string{"brint"}},
+		&ovs.Bridge{UUID: "brint", Name: "br-int", Ports: []string{"p1", "p2"}},
+		&ovs.Port{UUID: "p1", Name: "br-int", Interfaces: []string{"i1"}},
+		&ovs.Port{UUID: "p2", Name: "eth0", Interfaces: []string{"i2"}},
+		&ovs.Interface{
+			UUID:       "i1",
+			Name:       "br-int",
+			Type:       "internal",
+			AdminState: ptr("down"),
+			MTU:        ptr(1500),
+		},
+		&ovs.Interface{
+			UUID:       "i2",
+			Name:       "eth0",
+			AdminState: ptr("up"),
+			MTU:        ptr(9000),
+			Statistics: map[string]int{"rx_packets": 42},
+		},
+	)
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_interface_admin_state The administrative state of the interface. Possible values are: up(1), down(0) or unknown(-1).
+# TYPE ovs_interface_admin_state gauge
+ovs_interface_admin_state{bridge="br-int",interface="br-int",port="br-int",type="internal"} 0
+ovs_interface_admin_state{bridge="br-int",interface="eth0",port="eth0",type="system"} 1
+# HELP ovs_interface_mtu_bytes Maximum transmission unit size in bytes.
+# TYPE ovs_interface_mtu_bytes gauge
+ovs_interface_mtu_bytes{bridge="br-int",interface="br-int",port="br-int",type="internal"} 1500
+ovs_interface_mtu_bytes{bridge="br-int",interface="eth0",port="eth0",type="system"} 9000
+# HELP ovs_interface_rx_packets Number of received packets.
+# TYPE ovs_interface_rx_packets counter
+ovs_interface_rx_packets{bridge="br-int",interface="br-int",port="br-int",type="internal"} 0
+ovs_interface_rx_packets{bridge="br-int",interface="eth0",port="eth0",type="system"} 42
+`), "ovs_interface_admin_state", "ovs_interface_mtu_bytes", "ovs_interface_rx_packets")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"context"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collect runs a scrape of the collector within the configured scrape timeout
+// and logs any error. It is meant to be used to implement
+// prometheus.Collector.Collect.
+func Collect(c Collector, ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.ScrapeTimeout())
+	defer cancel()
+	if err := c.CollectWithContext(ctx, ch); err != nil {
+		Logger(c).With("error", err).Errf("scrape failed")
+	}
+}
+
+// Logger returns a logger for a collector which includes the name of its
+// instance, if any.
+func Logger(c Collector) *log.Logger {
+	l := log.Collector(c.Name())
+	if name := c.Instance().Name; name != "" {
+		l = l.With("instance", name)
+	}
+	return l
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"context"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metricSetFilter struct {
+	Collector
+	sets config.MetricSet
+	// metric set of each descriptor, indexed by Desc.String()
+	descSets map[string]config.MetricSet
+}
+
+// WithMetricSets wraps a collector so that it only exports the metrics which
+// belong to the given sets. The collector still only exports the metrics
+// enabled in the configuration.
+func WithMetricSets(c Collector, sets config.MetricSet) Collector {
+	f := &metricSetFilter{
+		Collector: c,
+		sets:      sets,
+		descSets:  make(map[string]config.MetricSet),
+	}
+	for _, m := range c.Metrics() {
+		f.descSets[m.Desc().String()] = m.Set
+	}
+	return f
+}
+
+func (f *metricSetFilter) enabled(d *prometheus.Desc) bool {
+	set, ok := f.descSets[d.String()]
+	return !ok || f.sets.Has(set)
+}
+
+func (f *metricSetFilter) Describe(ch chan<- *prometheus.Desc) {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		f.Collector.Describe(descs)
+		close(descs)
+	}()
+	for d := range descs {
+		if f.enabled(d) {
+			ch <- d
+		}
+	}
+}
+
+func (f *metricSetFilter) Collect(ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		f.Collector.Collect(metrics)
+		close(metrics)
+	}()
+	for m := range metrics {
+		if f.enabled(m.Desc()) {
+			ch <- m
+		}
+	}
+}
+
+func (f *metricSetFilter) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	metrics := make(chan prometheus.Metric)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- f.Collector.CollectWithContext(ctx, metrics)
+		close(metrics)
+	}()
+	for m := range metrics {
+		if f.enabled(m.Desc()) {
+			ch <- m
+		}
+	}
+	return <-errs
+}
@@ -1,12 +1,14 @@
 package lib
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -16,6 +18,12 @@ type Collector interface {
 
 	Name() string
 	Metrics() []Metric
+	// The OVS/OVN instance which the collector reads its metrics from.
+	Instance() *instance.Instance
+	// Send the collector metrics to ch. Return an error if the metrics
+	// could not be retrieved from the backends, even partially. Pending
+	// backend requests are aborted when ctx is done.
+	CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error
 }
 
 type Metric struct {
@@ -34,24 +42,44 @@ func (m *Metric) Desc() *prometheus.Desc {
 	return m.desc
 }
 
+// Return true if a metric of the named collector must be exported, according
+// to the metric sets enabled for that collector and the per metric
+// enable-metrics and disable-metrics overrides.
+func MetricEnabled(collector string, m *Metric) bool {
+	return config.MetricEnabled(collector, m.Name, m.Set)
+}
+
+// Return true if at least one of the metrics of the named collector must be
+// exported. This allows skipping backend calls altogether.
+func AnyMetricEnabled(collector string, metrics []Metric) bool {
+	for i := range metrics {
+		if MetricEnabled(collector, &metrics[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 func DescribeEnabledMetrics(c Collector, ch chan<- *prometheus.Desc) {
 	for _, m := range c.Metrics() {
-		if config.MetricSets().Has(m.Set) {
+		if MetricEnabled(c.Name(), &m) {
 			log.Debugf("%T: enabling metric %s", c, m.Name)
 			ch <- m.Desc()
 		}
 	}
 }
 
-var columns = []any{"METRIC", "COLLECTOR", "SET", "TYPE", "LABELS", "HELP"}
+var columns = []any{"METRIC", "COLLECTOR", "SET", "ENABLED", "TYPE", "LABELS", "HELP"}
 
 const (
-	textFmt     = "%s collector=%s set=%s type=%s labels=%s help=%q\n"
-	csvFmt      = "%s;%s;%s;%s;%s;%s;\n"
-	tsvFmt      = "%s\t%s\t%s\t%s\t%s\t%s\n"
-	markdownFmt = "| %s | %s | %s | %s | %s | %s |\n"
+	textFmt     = "%s collector=%s set=%s enabled=%v type=%s labels=%s help=%q\n"
+	csvFmt      = "%s;%s;%s;%v;%s;%s;%s;\n"
+	tsvFmt      = "%s\t%s\t%s\t%v\t%s\t%s\t%s\n"
+	markdownFmt = "| %s | %s | %s | %v | %s | %s | %s |\n"
 )
 
+// Print all metrics of the given collectors. The ENABLED column reflects the
+// active configuration.
 func PrintMetrics(collectors []Collector, format string) {
 	var jsonList []map[string]any
 
@@ -83,6 +111,7 @@ func PrintMetrics(collectors []Collector, format string) {
 				m.Name,
 				c.Name(),
 				m.Set.String(),
+				CollectorEnabled(c) && MetricEnabled(c.Name(), &m),
 				strings.ToLower(m.ValueType.ToDTO().String()),
 				strings.Join(m.Labels, ","),
 				m.Description,
@@ -95,6 +124,7 @@ func PrintMetrics(collectors []Collector, format string) {
 					"metric":    m.Name,
 					"collector": c.Name(),
 					"set":       m.Set.String(),
+					"enabled":   CollectorEnabled(c) && MetricEnabled(c.Name(), &m),
 					"type":      strings.ToLower(m.ValueType.ToDTO().String()),
 					"labels":    m.Labels,
 					"help":      m.Description,
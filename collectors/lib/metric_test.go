@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+)
+
+func TestMetricEnabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+metric-sets: [base]
+collector-metric-sets:
+  coverage: [errors, debug]
+enable-metrics: [ovs_memory_handlers_total]
+disable-metrics: [ovs_coverage_drop_total]
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		collector string
+		metric    Metric
+		enabled   bool
+	}{
+		{"bridge", Metric{Name: "ovs_bridge_ports", Set: config.METRICS_BASE}, true},
+		{"bridge", Metric{Name: "ovs_bridge_flows", Set: config.METRICS_PERF}, false},
+		{"coverage", Metric{Name: "ovs_coverage_dpif_execute_total", Set: config.METRICS_DEBUG}, true},
+		{"coverage", Metric{Name: "ovs_coverage_base", Set: config.METRICS_BASE}, false},
+		{"coverage", Metric{Name: "ovs_coverage_drop_total", Set: config.METRICS_ERRORS}, false},
+		{"memory", Metric{Name: "ovs_memory_handlers_total", Set: config.METRICS_PERF}, true},
+	} {
+		if MetricEnabled(tc.collector, &tc.metric) != tc.enabled {
+			t.Errorf("%s: %s: expected enabled=%v", tc.collector, tc.metric.Name, tc.enabled)
+		}
+	}
+}
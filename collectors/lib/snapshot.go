@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// The result of one scrape of a collector.
+type snapshot struct {
+	metrics  []prometheus.Metric
+	err      error
+	start    time.Time
+	end      time.Time
+	duration time.Duration
+}
+
+type shared struct {
+	Collector
+	group singleflight.Group
+	lock  sync.Mutex
+	last  *snapshot
+	// number of failed scrapes, by reason
+	errors   uint64
+	timeouts uint64
+}
+
+var (
+	sharedLock sync.Mutex
+	sharedMap  = make(map[string]*shared)
+)
+
+// Shared returns a wrapper around a collector which is unique for each
+// collector name and instance. Concurrent scrapes share the same in-flight collection of
+// the backends. When polling is enabled for the collector, scrapes return
+// the latest snapshot taken in the background by Poll instead.
+//
+// Collect also reports whether the scrape succeeded, how long it took, the
+// age of the returned metrics and the number of failed scrapes via the
+// selfmetrics descriptors.
+func Shared(c Collector) Collector {
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+
+	key := c.Instance().Name + "/" + c.Name()
+	s, ok := sharedMap[key]
+	if !ok {
+		s = &shared{Collector: c}
+		sharedMap[key] = s
+	}
+	return s
+}
+
+// Run a new collection within the configured scrape timeout, unless one is
+// already in progress in which case its result is returned. The collection
+// is also aborted when ctx is done.
+func (s *shared) refresh(ctx context.Context) *snapshot {
+	v, _, _ := s.group.Do(s.Name(), func() (any, error) {
+		ctx, cancel := context.WithTimeout(ctx, config.ScrapeTimeout())
+		defer cancel()
+
+		start := time.Now()
+		metrics := make(chan prometheus.Metric)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Collector.CollectWithContext(ctx, metrics)
+			close(metrics)
+		}()
+
+		snap := &snapshot{start: start}
+		for m := range metrics {
+			snap.metrics = append(snap.metrics, m)
+		}
+		snap.err = <-errs
+		snap.end = time.Now()
+		snap.duration = snap.end.Sub(start)
+
+		// backends may have been aborted without the collector noticing
+		if snap.err == nil && ctx.Err() != nil {
+			snap.err = fmt.Errorf("scrape aborted: %w", ctx.Err())
+		}
+		if snap.err != nil {
+			Logger(s).With("error", snap.err).Errf("scrape failed")
+		}
+
+		s.lock.Lock()
+		s.last = snap
+		if snap.err != nil {
+			// backend requests may also time out on their own
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) ||
+				errors.Is(snap.err, context.DeadlineExceeded) {
+				s.timeouts++
+			} else {
+				s.errors++
+			}
+		}
+		s.lock.Unlock()
+
+		return snap, nil
+	})
+	return v.(*snapshot)
+}
+
+func (s *shared) latest() *snapshot {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.last
+}
+
+// Return the number of failed scrapes, by reason.
+func (s *shared) failures() (errs, timeouts uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.errors, s.timeouts
+}
+
+// Return the latest background snapshot if polling is enabled. Otherwise,
+// run a new collection.
+func (s *shared) snapshot(ctx context.Context) *snapshot {
+	if config.PollInterval(s.Name()) > 0 {
+		if snap := s.latest(); snap != nil {
+			return snap
+		}
+	}
+	return s.refresh(ctx)
+}
+
+func (s *shared) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	snap := s.snapshot(ctx)
+	for _, m := range snap.metrics {
+		ch <- m
+	}
+	return snap.err
+}
+
+func (s *shared) Collect(ch chan<- prometheus.Metric) {
+	snap := s.snapshot(context.Background())
+	for _, m := range snap.metrics {
+		ch <- m
+	}
+
+	success := 1.0
+	if snap.err != nil {
+		success = 0
+	}
+	ch <- prometheus.MustNewConstMetric(selfmetrics.CollectorSuccess,
+		prometheus.GaugeValue, success, s.Name())
+	ch <- prometheus.MustNewConstMetric(selfmetrics.CollectorDuration,
+		prometheus.GaugeValue, snap.duration.Seconds(), s.Name())
+	ch <- prometheus.MustNewConstMetric(selfmetrics.CollectorSnapshotAge,
+		prometheus.GaugeValue, time.Since(snap.end).Seconds(), s.Name())
+
+	errs, timeouts := s.failures()
+	ch <- prometheus.MustNewConstMetric(selfmetrics.CollectorErrors,
+		prometheus.CounterValue, float64(errs), s.Name(), "error")
+	ch <- prometheus.MustNewConstMetric(selfmetrics.CollectorErrors,
+		prometheus.CounterValue, float64(timeouts), s.Name(), "timeout")
+}
+
+// How often Poll checks whether collectors need to be refreshed.
+const pollTick = 250 * time.Millisecond
+
+// Poll refreshes the snapshots of the enabled collectors which have polling
+// enabled, each at its own interval. The intervals are read from the active
+// configuration and follow reloads. Poll never returns.
+func Poll(collectors []Collector) {
+	for range time.Tick(pollTick) {
+		for _, c := range collectors {
+			interval := config.PollInterval(c.Name())
+			if interval <= 0 || !CollectorEnabled(c) {
+				continue
+			}
+			s := Shared(c).(*shared)
+			if snap := s.latest(); snap == nil || time.Since(snap.start) >= interval {
+				go s.refresh(context.Background())
+			}
+		}
+	}
+}
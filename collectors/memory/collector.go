@@ -4,20 +4,33 @@
 package memory
 
 import (
+	"context"
+	"fmt"
 	"regexp"
 	"strconv"
 
-	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Collector struct{}
+const collectorName = "memory"
+
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "memory"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -34,25 +47,32 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 
 var memoryCountRe = regexp.MustCompile(`(\w+):(\d+)`)
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
-	buf := appctl.OvsVSwitchd("memory/show")
-	if buf == "" {
-		return
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	buf, err := c.inst.Appctl.OvsVSwitchd(ctx, "memory/show")
+	if err != nil {
+		return fmt.Errorf("memory/show: %w", err)
 	}
 
+	logger := lib.Logger(c)
 	for _, match := range memoryCountRe.FindAllStringSubmatch(buf, -1) {
 		m, ok := metrics[match[1]]
 		if !ok {
 			continue
 		}
-		if !config.MetricSets().Has(m.Set) {
+		if !lib.MetricEnabled(collectorName, &m) {
 			continue
 		}
 		val, err := strconv.ParseFloat(match[2], 64)
 		if err != nil {
-			log.Errf("%s: %s: %s", match[1], match[2], err)
+			logger.With("error", err).Errf("%s: %s", match[1], match[2])
 			continue
 		}
 		ch <- prometheus.MustNewConstMetric(m.Desc(), m.ValueType, val)
 	}
+
+	return nil
 }
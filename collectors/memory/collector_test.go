@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	vswitchd := appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+	vswitchd.Reply("memory/show",
+		"handlers:29 idl-cells-Open_vSwitch:7351 ports:114 revalidators:11 rules:190 udpif keys:76\n")
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_memory_handlers_total Total number of handler threads.
+# TYPE ovs_memory_handlers_total gauge
+ovs_memory_handlers_total 29
+# HELP ovs_memory_ports_total Total number of ports.
+# TYPE ovs_memory_ports_total gauge
+ovs_memory_ports_total 114
+# HELP ovs_memory_revalidators_total Total number of revalidator threads.
+# TYPE ovs_memory_revalidators_total gauge
+ovs_memory_revalidators_total 11
+# HELP ovs_memory_rules_total Total number of rules.
+# TYPE ovs_memory_rules_total gauge
+ovs_memory_rules_total 190
+`), "ovs_memory_handlers_total", "ovs_memory_ports_total",
+		"ovs_memory_revalidators_total", "ovs_memory_rules_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorNoDaemon(t *testing.T) {
+	if err := New(instancetest.New(t)).CollectWithContext(context.Background(), nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestCollectorTimeout(t *testing.T) {
+	inst := instancetest.New(t)
+	vswitchd := appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+	hang := make(chan struct{})
+	// registered after the server, runs before it is closed
+	t.Cleanup(func() { close(hang) })
+	vswitchd.Handle("memory/show", func([]string) (string, error) {
+		<-hang
+		return "", nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := New(inst).CollectWithContext(ctx, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("scrape not aborted after %s", d)
+	}
+}
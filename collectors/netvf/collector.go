@@ -3,22 +3,41 @@
 package netvf
 
 import (
+	"context"
 	"fmt"
+	"slices"
 
 	"github.com/jsimonetti/rtnetlink/v2"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	internalsysfs "github.com/openstack-k8s-operators/openstack-network-exporter/internal/sysfs"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-const sysfsRoot = "/sys"
+const collectorName = "netvf"
 
-type Collector struct{}
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector bound to an instance. The VF metrics are read from
+// the host and do not depend on the instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+// HostWide reports that the collector metrics are the same for all instances.
+func (Collector) HostWide() bool {
+	return true
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "netvf"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -29,56 +48,59 @@ func (Collector) Metrics() []lib.Metric {
 	return res
 }
 
-func (c Collector) Describe(ch chan<- *prometheus.Desc) {
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	lib.DescribeEnabledMetrics(c, ch)
 }
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
-	conn, err := rtnetlink.Dial(nil)
-	if err != nil {
-		log.Errf("netvf: failed to connect to rtnetlink: %s", err)
-		return
-	}
-	defer conn.Close()
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
 
-	links, err := conn.Link.ListWithVFInfo()
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	links, err := c.inst.Netlink.Links()
 	if err != nil {
-		log.Errf("netvf: failed to list interfaces: %s", err)
-		return
+		return err
 	}
 
-	sets := config.MetricSets()
+	// skip excluded devices before reading their sysfs attributes
+	filters := config.Filters(c.Name())
+	links = slices.DeleteFunc(links, func(link rtnetlink.LinkMessage) bool {
+		return link.Attributes != nil && !filters.Interface.Match(link.Attributes.Name)
+	})
+
 	buf := make(chan prometheus.Metric)
 	go func() {
-		collectFromLinks(links, sysfsRoot, buf)
+		collectFromLinks(links, c.inst.Netlink.SysfsRoot(), buf)
 		close(buf)
 	}()
 	for m := range buf {
-		if sets.Has(metricSet(m)) {
+		if def := metricDef(m); def == nil || lib.MetricEnabled(collectorName, def) {
 			ch <- m
 		}
 	}
+
+	return nil
 }
 
-// metricSet returns the MetricSet for a given prometheus.Metric by matching
+// metricDef returns the definition of a given prometheus.Metric by matching
 // its Desc against the known metrics.
-func metricSet(m prometheus.Metric) config.MetricSet {
+func metricDef(m prometheus.Metric) *lib.Metric {
 	d := m.Desc()
 	if d == infoMetric.Desc() {
-		return infoMetric.Set
+		return &infoMetric
 	}
 	for _, cm := range counterMetrics {
 		if d == cm.Desc() {
-			return cm.Set
+			return cm
 		}
 	}
-	return config.METRICS_BASE
+	return nil
 }
 
 // collectFromLinks is the testable core: processes rtnetlink link messages and
 // emits metrics to ch. sysfsRoot is "/sys" in production, a temp dir in tests.
 // It emits all metrics unconditionally; callers are responsible for filtering
-// by MetricSet.
+// with lib.MetricEnabled.
 func collectFromLinks(links []rtnetlink.LinkMessage, sysfsRoot string, ch chan<- prometheus.Metric) {
 	for _, link := range links {
 		if link.Attributes == nil {
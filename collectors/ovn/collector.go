@@ -6,34 +6,35 @@ package ovn
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func collectopenvSwitch(externaIds map[string]string, ch chan<- prometheus.Metric) {
+const collectorName = "ovn"
+
+func collectopenvSwitch(logger *log.Logger, externaIds map[string]string, ch chan<- prometheus.Metric) {
 	for name, metric := range openvSwitch {
 		value, ok := externaIds[name]
 		if !ok {
 			continue
 		}
-		if !config.MetricSets().Has(metric.Set) {
+		if !lib.MetricEnabled(collectorName, &metric) {
 			continue
 		}
 
 		val, err := strconv.ParseFloat(value, 64)
 		if err != nil {
-			log.Errf("%s: %s: %s", name, value, err)
+			logger.With("error", err).Errf("%s: %s", name, value)
 			continue
 		}
 
@@ -47,7 +48,7 @@ func collectopenvSwitchBoolean(externaIds map[string]string, ch chan<- prometheu
 		if !ok {
 			continue
 		}
-		if !config.MetricSets().Has(metric.Set) {
+		if !lib.MetricEnabled(collectorName, &metric) {
 			continue
 		}
 
@@ -80,7 +81,7 @@ func collectopenvSwitchLabels(externaIds map[string]string, ch chan<- prometheus
 		if !ok {
 			continue
 		}
-		if !config.MetricSets().Has(metric.Set) {
+		if !lib.MetricEnabled(collectorName, &metric) {
 			continue
 		}
 
@@ -91,7 +92,7 @@ func collectopenvSwitchLabels(externaIds map[string]string, ch chan<- prometheus
 	if !ok {
 		return
 	}
-	if !config.MetricSets().Has(bridgeMappings.Set) {
+	if !lib.MetricEnabled(collectorName, &bridgeMappings) {
 		return
 	}
 	for network, bridge := range parse_mappings(extIds) {
@@ -100,18 +101,18 @@ func collectopenvSwitchLabels(externaIds map[string]string, ch chan<- prometheus
 	}
 }
 
-func makeMetric(name, value string) prometheus.Metric {
+func makeMetric(logger *log.Logger, name, value string) prometheus.Metric {
 	m, ok := ovnController[name]
 	if !ok {
 		return nil
 	}
-	if !config.MetricSets().Has(m.Set) {
+	if !lib.MetricEnabled(collectorName, &m) {
 		return nil
 	}
 
 	val, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		log.Errf("%s: %s: %s", name, value, err)
+		logger.With("error", err).Errf("%s: %s", name, value)
 		return nil
 	}
 
@@ -135,16 +136,16 @@ func isPacketInDropComponent(name string) bool {
 // "vconn_sent                 0.0/sec     0.083/sec        0.0767/sec   total: 131870"
 var coverageRe = regexp.MustCompile(`^(\w+)\s+.*\s+total: (\d+)$`)
 
-func collectCoverageMetrics(ch chan<- prometheus.Metric) {
+func collectCoverageMetrics(ctx context.Context, logger *log.Logger, inst *instance.Instance, ch chan<- prometheus.Metric) error {
 
 	packetInDropComponets := map[string]string{
 		dropBufferedPacketsMap: "",
 		dropControllerEvent:    "",
 	}
 
-	buf := appctl.OvnController("coverage/show")
-	if buf == "" {
-		return
+	buf, err := inst.Appctl.OvnController(ctx, "coverage/show")
+	if err != nil {
+		return fmt.Errorf("coverage/show: %w", err)
 	}
 
 	scanner := bufio.NewScanner(strings.NewReader(buf))
@@ -156,7 +157,7 @@ func collectCoverageMetrics(ch chan<- prometheus.Metric) {
 			if isPacketInDropComponent(match[1]) {
 				packetInDropComponets[match[1]] = match[2]
 			} else {
-				metric := makeMetric(match[1], match[2])
+				metric := makeMetric(logger, match[1], match[2])
 				if metric != nil {
 					ch <- metric
 				}
@@ -169,27 +170,28 @@ func collectCoverageMetrics(ch chan<- prometheus.Metric) {
 		if v != "" {
 			i, e := strconv.Atoi(v)
 			if e != nil {
-				log.Errf("%s: %s: %s", m, v, e)
+				logger.With("error", e).Errf("%s: %s", m, v)
 				continue
 			}
 			total += i
 		}
 	}
 	if total > 0 {
-		metric := makeMetric(packetInDrop, strconv.Itoa(total))
+		metric := makeMetric(logger, packetInDrop, strconv.Itoa(total))
 		if metric != nil {
 			ch <- metric
 		}
 	}
+
+	return nil
 }
 
-func collectLogicalRouters(ch chan<- prometheus.Metric) {
+func collectLogicalRouters(ctx context.Context, inst *instance.Instance, ch chan<- prometheus.Metric) error {
 	var value float64
 
-	rps, err := openflow.GetRouterPortsStats()
+	rps, err := inst.Openflow.RouterPortsStats(ctx)
 	if err != nil {
-		log.Errf("Error getting router ports statistics: %s", err)
-		return
+		return fmt.Errorf("router ports statistics: %w", err)
 	}
 
 	for _, s := range rps {
@@ -199,7 +201,7 @@ func collectLogicalRouters(ch chan<- prometheus.Metric) {
 		}
 
 		for name, metric := range ovnRouterPortTraffic {
-			if !config.MetricSets().Has(metric.Set) {
+			if !lib.MetricEnabled(collectorName, &metric) {
 				continue
 			}
 			if name == routerporttrafficpkts {
@@ -211,12 +213,25 @@ func collectLogicalRouters(ch chan<- prometheus.Metric) {
 			ch <- prometheus.MustNewConstMetric(metric.Desc(), metric.ValueType, value, labels...)
 		}
 	}
+
+	return nil
+}
+
+type Collector struct {
+	inst *instance.Instance
 }
 
-type Collector struct{}
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "ovn"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -234,23 +249,28 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	lib.DescribeEnabledMetrics(c, ch)
 }
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	logger := lib.Logger(c)
+
 	// collect items from the ExternalIDs field in the OpenvSwitch table
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
 	var vswitch ovs.OpenvSwitch
-	err := ovsdb.Get(ctx, &vswitch)
+	err := ovsdb.Get(ctx, c.inst.Ovsdb, &vswitch)
 	if err != nil {
-		log.Errf("OvsdbGet(vswitch): %s", err)
-		return
+		return fmt.Errorf("OvsdbGet(vswitch): %w", err)
 	}
-	collectopenvSwitch(vswitch.ExternalIDs, ch)
+	collectopenvSwitch(logger, vswitch.ExternalIDs, ch)
 	collectopenvSwitchBoolean(vswitch.ExternalIDs, ch)
 	collectopenvSwitchLabels(vswitch.ExternalIDs, ch)
 
 	// collect the ovn-controller coverage metrics
-	collectCoverageMetrics(ch)
+	errCoverage := collectCoverageMetrics(ctx, logger, c.inst, ch)
 
 	// collect the logical router and logical router ports metrics
-	collectLogicalRouters(ch)
+	errRouters := collectLogicalRouters(ctx, c.inst, ch)
+
+	return errors.Join(errCoverage, errRouters)
 }
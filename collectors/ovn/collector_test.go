@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ovn
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow/openflowtest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovsdbtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	db := ovsdbtest.NewServer(t, inst.OvsRundir)
+	db.Insert(t, &ovs.OpenvSwitch{
+		ExternalIDs: map[string]string{
+			"ovn-monitor-all":             "true",
+			"ovn-encap-ip":                "172.19.0.100",
+			"ovn-encap-type":              "geneve",
+			"ovn-bridge-mappings":         "datacentre:br-ex, tenant:br-tenant",
+			"ovn-remote-probe-interval":   "60000",
+			"ovn-openflow-probe-interval": "60",
+		},
+	})
+	controller := appctltest.NewServer(t, inst.OvnRundir, "ovn-controller")
+	controller.Reply("coverage/show", `Event coverage, avg rate over last: 5 seconds, last minute, last hour,  hash=3c36f4ac:
+txn_error                  0.0/sec     0.000/sec        0.0000/sec   total: 3
+pinctrl_drop_buffered_packets_map   0.0/sec     0.000/sec        0.0000/sec   total: 2
+pinctrl_drop_controller_event   0.0/sec     0.000/sec        0.0000/sec   total: 5
+`)
+	openflowtest.NewBridge(t, inst.OvsRundir, "br-int",
+		openflowtest.RouterPortFlow(4, 2, 100, 6400),
+		// not a router port, no clone action
+		openflowtest.Flow{Table: 65, Packets: 1, Bytes: 64},
+	)
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovnc_bridge_mappings A metric with a constant '1' value labeled by mapping that specifies a list of key-value pairs that map a physical network name to a local ovs bridge that provides connectivity to that network.
+# TYPE ovnc_bridge_mappings gauge
+ovnc_bridge_mappings{bridge="br-ex",network="datacentre"} 1
+ovnc_bridge_mappings{bridge="br-tenant",network="tenant"} 1
+# HELP ovnc_encap_ip A metric with a constant '1' value labeled by ipadress that specifies the encapsulation ip address configured on that node
+# TYPE ovnc_encap_ip gauge
+ovnc_encap_ip{encap_ip="172.19.0.100"} 1
+# HELP ovnc_encap_type A metric with a constant '1' value labeled by type that specifies the encapsulation type that a chassis should use to connect to this node
+# TYPE ovnc_encap_type gauge
+ovnc_encap_type{encap_type="geneve"} 1
+# HELP ovnc_monitor_all Specifies if ovn-controller should monitor all records of tables in OVN SB DB. The value of 0 means it will conditionally monitor the records that are needed in the current chassis
+# TYPE ovnc_monitor_all gauge
+ovnc_monitor_all 1
+# HELP ovnc_openflow_probe_interval Maximum number of milliseconds of idle time on OpenFlow connection to the OVS bridge before sending an inactivity probe message
+# TYPE ovnc_openflow_probe_interval gauge
+ovnc_openflow_probe_interval 60
+# HELP ovnc_packet_in_drop Specifies the number of times the ovn-controller has dropped the packet-ins from ovs-vswitchd due to resource constraints
+# TYPE ovnc_packet_in_drop counter
+ovnc_packet_in_drop 7
+# HELP ovnc_remote_probe_interval Maximum number of milliseconds of idle time on connection to the OVN SB DB before sending an inactivity probe message
+# TYPE ovnc_remote_probe_interval gauge
+ovnc_remote_probe_interval 60000
+# HELP ovnc_router_port_traffic_bytes Number of bytes transmitted and received by a logical router port labeled by the logical datapath number and the logical port number
+# TYPE ovnc_router_port_traffic_bytes gauge
+ovnc_router_port_traffic_bytes{datapath="4",port="2"} 6400
+# HELP ovnc_router_port_traffic_pkts Number of packets transmitted and received by a logical router port labeled by the logical datapath number and the logical port number
+# TYPE ovnc_router_port_traffic_pkts gauge
+ovnc_router_port_traffic_pkts{datapath="4",port="2"} 100
+# HELP ovnc_txn_error Specifies the number of times the OVSDB transaction has errored out
+# TYPE ovnc_txn_error counter
+ovnc_txn_error 3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorNoController(t *testing.T) {
+	inst := instancetest.New(t)
+	db := ovsdbtest.NewServer(t, inst.OvsRundir)
+	db.Insert(t, &ovs.OpenvSwitch{})
+	openflowtest.NewBridge(t, inst.OvsRundir, "br-int")
+
+	if err := New(inst).CollectWithContext(context.Background(), make(chan<- prometheus.Metric, 100)); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -5,21 +5,36 @@ package ovnnorthd
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Collector struct{}
+const collectorName = "ovnnorthd"
+
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "ovnnorthd"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -35,18 +50,18 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	lib.DescribeEnabledMetrics(c, ch)
 }
 
-func makeMetric(name, value string) prometheus.Metric {
+func makeMetric(logger *log.Logger, name, value string) prometheus.Metric {
 	m, ok := coverageMetrics[name]
 	if !ok {
 		return nil
 	}
-	if !config.MetricSets().Has(m.Set) {
+	if !lib.MetricEnabled(collectorName, &m) {
 		return nil
 	}
 
 	val, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		log.Errf("%s: %s: %s", name, value, err)
+		logger.With("error", err).Errf("%s: %s", name, value)
 		return nil
 	}
 
@@ -56,10 +71,10 @@ func makeMetric(name, value string) prometheus.Metric {
 // "pstream_open                 0.0/sec     0.000/sec        0.0000/sec   total: 1"
 var coverageRe = regexp.MustCompile(`^(\w+)\s+.*\s+total: (\d+)$`)
 
-func collectCoverageMetrics(ch chan<- prometheus.Metric) {
-	buf := appctl.OvnNorthd("coverage/show")
-	if buf == "" {
-		return
+func collectCoverageMetrics(ctx context.Context, logger *log.Logger, inst *instance.Instance, ch chan<- prometheus.Metric) error {
+	buf, err := inst.Appctl.OvnNorthd(ctx, "coverage/show")
+	if err != nil {
+		return fmt.Errorf("coverage/show: %w", err)
 	}
 
 	scanner := bufio.NewScanner(strings.NewReader(buf))
@@ -68,22 +83,24 @@ func collectCoverageMetrics(ch chan<- prometheus.Metric) {
 
 		match := coverageRe.FindStringSubmatch(line)
 		if match != nil {
-			metric := makeMetric(match[1], match[2])
+			metric := makeMetric(logger, match[1], match[2])
 			if metric != nil {
 				ch <- metric
 			}
 		}
 	}
+
+	return nil
 }
 
-func collectStatusMetric(ch chan<- prometheus.Metric) {
-	if !config.MetricSets().Has(statusMetric.Set) {
-		return
+func collectStatusMetric(ctx context.Context, logger *log.Logger, inst *instance.Instance, ch chan<- prometheus.Metric) error {
+	if !lib.MetricEnabled(collectorName, &statusMetric) {
+		return nil
 	}
 
-	buf := appctl.OvnNorthd("status")
-	if buf == "" {
-		return
+	buf, err := inst.Appctl.OvnNorthd(ctx, "status")
+	if err != nil {
+		return fmt.Errorf("status: %w", err)
 	}
 
 	var value float64
@@ -102,25 +119,35 @@ func collectStatusMetric(ch chan<- prometheus.Metric) {
 			case "paused":
 				value = 2.0
 			default:
-				log.Warningf("Unknown northd status: %s", statusValue)
-				return
+				logger.Warningf("Unknown northd status: %s", statusValue)
+				return nil
 			}
 		} else {
-			log.Warningf("Unexpected status format: %s", status)
-			return
+			logger.Warningf("Unexpected status format: %s", status)
+			return nil
 		}
 	} else {
-		log.Warningf("Status output does not contain 'Status:' prefix: %s", status)
-		return
+		logger.Warningf("Status output does not contain 'Status:' prefix: %s", status)
+		return nil
 	}
 
 	ch <- prometheus.MustNewConstMetric(statusMetric.Desc(), statusMetric.ValueType, value)
+
+	return nil
 }
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	logger := lib.Logger(c)
+
 	// Collect coverage metrics
-	collectCoverageMetrics(ch)
+	errCoverage := collectCoverageMetrics(ctx, logger, c.inst, ch)
 
 	// Collect status metric
-	collectStatusMetric(ch)
+	errStatus := collectStatusMetric(ctx, logger, c.inst, ch)
+
+	return errors.Join(errCoverage, errStatus)
 }
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ovnnorthd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	northd := appctltest.NewServer(t, inst.OvnRundir, "ovn-northd")
+	northd.Reply("coverage/show", `Event coverage, avg rate over last: 5 seconds, last minute, last hour,  hash=3c36f4ac:
+pstream_open               0.0/sec     0.000/sec        0.0000/sec   total: 1
+txn_success                0.2/sec     0.150/sec        0.1411/sec   total: 4231
+`)
+	northd.Reply("status", "Status: active\n")
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovn_northd_pstream_open_total Specifies the number of time passive connections were opened for the remote peer to connect
+# TYPE ovn_northd_pstream_open_total counter
+ovn_northd_pstream_open_total 1
+# HELP ovn_northd_status Status of OVN northd (0=standby, 1=active, 2=paused)
+# TYPE ovn_northd_status gauge
+ovn_northd_status 1
+# HELP ovn_northd_txn_success_total Specifies the number of times the OVSDB transaction has successfully completed
+# TYPE ovn_northd_txn_success_total counter
+ovn_northd_txn_success_total 4231
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorStatus(t *testing.T) {
+	inst := instancetest.New(t)
+	northd := appctltest.NewServer(t, inst.OvnRundir, "ovn-northd")
+	northd.Reply("coverage/show", "")
+
+	for _, tc := range []struct {
+		status string
+		value  string
+	}{
+		{"standby", "0"},
+		{"paused", "2"},
+	} {
+		northd.Reply("status", "Status: "+tc.status+"\n")
+		err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovn_northd_status Status of OVN northd (0=standby, 1=active, 2=paused)
+# TYPE ovn_northd_status gauge
+ovn_northd_status `+tc.value+`
+`), "ovn_northd_status")
+		if err != nil {
+			t.Fatalf("%s: %s", tc.status, err)
+		}
+	}
+}
@@ -5,17 +5,19 @@ package ovsdbserver
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const collectorName = "ovsdbserver"
+
 type raftClusterInfo struct {
 	database        string
 	clusterUUID     string
@@ -173,56 +175,56 @@ func collectRaftMetrics(info *raftClusterInfo, ch chan<- prometheus.Metric) {
 	baseLabels := []string{info.database, info.clusterUUID, info.serverUUID}
 
 	// Cluster election timer
-	if config.MetricSets().Has(clusterElectionTimer.Set) {
+	if lib.MetricEnabled(collectorName, &clusterElectionTimer) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterElectionTimer.Desc(), clusterElectionTimer.ValueType,
 			float64(info.electionTimer), baseLabels...)
 	}
 
 	// Cluster ID (constant 1.0)
-	if config.MetricSets().Has(clusterId.Set) {
+	if lib.MetricEnabled(collectorName, &clusterId) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterId.Desc(), clusterId.ValueType,
 			1.0, info.database, info.clusterUUID)
 	}
 
 	// Cluster Server ID (constant 1.0)
-	if config.MetricSets().Has(clusterServerId.Set) {
+	if lib.MetricEnabled(collectorName, &clusterServerId) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterServerId.Desc(), clusterServerId.ValueType,
 			1.0, baseLabels...)
 	}
 
 	// Cluster Server Role (constant 1.0)
-	if config.MetricSets().Has(clusterServerRole.Set) {
+	if lib.MetricEnabled(collectorName, &clusterServerRole) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterServerRole.Desc(), clusterServerRole.ValueType,
 			1.0, append(baseLabels, info.role)...)
 	}
 
 	// Cluster Server Status (constant 1.0)
-	if config.MetricSets().Has(clusterServerStatus.Set) {
+	if lib.MetricEnabled(collectorName, &clusterServerStatus) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterServerStatus.Desc(), clusterServerStatus.ValueType,
 			1.0, append(baseLabels, info.status)...)
 	}
 
 	// Cluster Server Vote (constant 1.0)
-	if config.MetricSets().Has(clusterServerVote.Set) {
+	if lib.MetricEnabled(collectorName, &clusterServerVote) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterServerVote.Desc(), clusterServerVote.ValueType,
 			1.0, append(baseLabels, info.vote)...)
 	}
 
 	// Cluster Term
-	if config.MetricSets().Has(clusterTerm.Set) {
+	if lib.MetricEnabled(collectorName, &clusterTerm) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterTerm.Desc(), clusterTerm.ValueType,
 			float64(info.term), baseLabels...)
 	}
 
 	// Cluster Leader (1.0 if leader, 0.0 if not)
-	if config.MetricSets().Has(clusterLeader.Set) {
+	if lib.MetricEnabled(collectorName, &clusterLeader) {
 		var leaderValue float64
 		if info.isLeader {
 			leaderValue = 1.0
@@ -235,52 +237,63 @@ func collectRaftMetrics(info *raftClusterInfo, ch chan<- prometheus.Metric) {
 	}
 
 	// Inbound connections
-	if config.MetricSets().Has(clusterInboundConnectionsTotal.Set) {
+	if lib.MetricEnabled(collectorName, &clusterInboundConnectionsTotal) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterInboundConnectionsTotal.Desc(), clusterInboundConnectionsTotal.ValueType,
 			float64(info.inboundConns), baseLabels...)
 	}
 
 	// Outbound connections
-	if config.MetricSets().Has(clusterOutboundConnectionsTotal.Set) {
+	if lib.MetricEnabled(collectorName, &clusterOutboundConnectionsTotal) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterOutboundConnectionsTotal.Desc(), clusterOutboundConnectionsTotal.ValueType,
 			float64(info.outboundConns), baseLabels...)
 	}
 
 	// Log entry index
-	if config.MetricSets().Has(logEntryIndex.Set) {
+	if lib.MetricEnabled(collectorName, &logEntryIndex) {
 		ch <- prometheus.MustNewConstMetric(
 			logEntryIndex.Desc(), logEntryIndex.ValueType,
 			float64(info.logStart), baseLabels...)
 	}
 
 	// Log index next
-	if config.MetricSets().Has(clusterLogIndexNext.Set) {
+	if lib.MetricEnabled(collectorName, &clusterLogIndexNext) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterLogIndexNext.Desc(), clusterLogIndexNext.ValueType,
 			float64(info.logNext), baseLabels...)
 	}
 
 	// Log not committed
-	if config.MetricSets().Has(clusterLogNotCommitted.Set) {
+	if lib.MetricEnabled(collectorName, &clusterLogNotCommitted) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterLogNotCommitted.Desc(), clusterLogNotCommitted.ValueType,
 			float64(info.logNotCommitted), baseLabels...)
 	}
 
 	// Log not applied
-	if config.MetricSets().Has(clusterLogNotApplied.Set) {
+	if lib.MetricEnabled(collectorName, &clusterLogNotApplied) {
 		ch <- prometheus.MustNewConstMetric(
 			clusterLogNotApplied.Desc(), clusterLogNotApplied.ValueType,
 			float64(info.logNotApplied), baseLabels...)
 	}
 }
 
-type Collector struct{}
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "ovsdbserver"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -291,18 +304,22 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	lib.DescribeEnabledMetrics(c, ch)
 }
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
-	output := appctl.OvsDbServer("cluster/status")
-	if output == "" {
-		log.Debugf("No OVN Raft cluster status output available")
-		return
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	output, err := c.inst.Appctl.OvsDbServer(ctx, "cluster/status")
+	if err != nil {
+		return fmt.Errorf("cluster/status: %w", err)
 	}
 
 	info, err := parseClusterStatus(output)
 	if err != nil {
-		log.Errf("Failed to parse OVN Raft cluster status: %s", err)
-		return
+		return fmt.Errorf("failed to parse OVN Raft cluster status: %w", err)
 	}
 
 	collectRaftMetrics(info, ch)
+
+	return nil
 }
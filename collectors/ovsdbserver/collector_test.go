@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package ovsdbserver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const clusterStatus = `5fa4
+Name: OVN_Southbound
+Cluster ID: 9d2c (9d2c5a3e-8e6c-4e5a-bd1f-3c1b9c1a6f0e)
+Server ID: 5fa4 (5fa4e7c2-0b1e-4c53-9a0e-0f4bcb1d2a11)
+Address: ssl:172.17.0.10:6644
+Status: cluster member
+Role: leader
+Term: 7
+Leader: self
+Vote: self
+
+Last Election started 1234 ms ago, reason: timeout
+Election timer: 10000
+Log: [2, 1043]
+Entries not yet committed: 0
+Entries not yet applied: 0
+Connections: ->0000 ->7b1c <-7b1c <-0000
+Disconnections: 0
+Servers:
+    5fa4 (5fa4 at ssl:172.17.0.10:6644) (self) next_index=1042 match_index=1042
+`
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	server := appctltest.NewServer(t, inst.OvsdbRundir, "ovsdb-server")
+	server.Handle("cluster/status", func(args []string) (string, error) {
+		if len(args) != 1 || args[0] != "OVN_Southbound" {
+			return "", fmt.Errorf("unexpected arguments: %v", args)
+		}
+		return clusterStatus, nil
+	})
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovn_raft_cluster_id A metric with a constant '1' value labeled by database name and cluster uuid
+# TYPE ovn_raft_cluster_id gauge
+ovn_raft_cluster_id{cluster_uuid="9d2c5a3e-8e6c-4e5a-bd1f-3c1b9c1a6f0e",database="OVN_Southbound"} 1
+# HELP ovn_raft_cluster_leader A metric with value 1.0 if the server is the cluster leader for the given database or 0.0 if it is not, labeled by database name, cluster uuid, and server uuid
+# TYPE ovn_raft_cluster_leader gauge
+ovn_raft_cluster_leader{cluster_uuid="9d2c5a3e-8e6c-4e5a-bd1f-3c1b9c1a6f0e",database="OVN_Southbound",server_uuid="5fa4e7c2-0b1e-4c53-9a0e-0f4bcb1d2a11"} 1
+# HELP ovn_raft_cluster_server_role A metric with a constant '1' value labeled by database name, cluster uuid, server uuid and role
+# TYPE ovn_raft_cluster_server_role gauge
+ovn_raft_cluster_server_role{cluster_uuid="9d2c5a3e-8e6c-4e5a-bd1f-3c1b9c1a6f0e",database="OVN_Southbound",role="leader",server_uuid="5fa4e7c2-0b1e-4c53-9a0e-0f4bcb1d2a11"} 1
+# HELP ovn_raft_cluster_term A metric with the value of the cluster term labeled by database name, cluster uuid, and server uuid
+# TYPE ovn_raft_cluster_term gauge
+ovn_raft_cluster_term{cluster_uuid="9d2c5a3e-8e6c-4e5a-bd1f-3c1b9c1a6f0e",database="OVN_Southbound",server_uuid="5fa4e7c2-0b1e-4c53-9a0e-0f4bcb1d2a11"} 7
+`), "ovn_raft_cluster_id", "ovn_raft_cluster_leader", "ovn_raft_cluster_server_role",
+		"ovn_raft_cluster_term")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,39 +5,53 @@ package pmd_perf
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func makeMetric(numa, cpu, name, value string) prometheus.Metric {
+const collectorName = "pmd-perf"
+
+func makeMetric(logger *log.Logger, numa, cpu, name, value string) prometheus.Metric {
 	m, ok := metrics[name]
 	if !ok {
 		return nil
 	}
-	if !config.MetricSets().Has(m.Set) {
+	if !lib.MetricEnabled(collectorName, &m) {
 		return nil
 	}
 
 	val, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		log.Errf("%s: %s: %s", name, value, err)
+		logger.With("error", err).Errf("%s: %s", name, value)
 		return nil
 	}
 
 	return prometheus.MustNewConstMetric(m.Desc(), m.ValueType, val, numa, cpu)
 }
 
-type Collector struct{}
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "pmd-perf"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -59,12 +73,17 @@ var (
 	pmdPerfStatRe = regexp.MustCompile(`(?m)^\s*([^:]+):\s+(\d+)\s*(.*)$`)
 )
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
-	buf := appctl.OvsVSwitchd("dpif-netdev/pmd-perf-show")
-	if buf == "" {
-		return
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	buf, err := c.inst.Appctl.OvsVSwitchd(ctx, "dpif-netdev/pmd-perf-show")
+	if err != nil {
+		return fmt.Errorf("dpif-netdev/pmd-perf-show: %w", err)
 	}
 
+	logger := lib.Logger(c)
 	numa := ""
 	cpu := ""
 
@@ -75,7 +94,7 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 		if numa != "" && cpu != "" {
 			match := pmdPerfStatRe.FindStringSubmatch(line)
 			if match != nil {
-				metric := makeMetric(numa, cpu, match[1], match[2])
+				metric := makeMetric(logger, numa, cpu, match[1], match[2])
 				if metric != nil {
 					ch <- metric
 				}
@@ -89,4 +108,6 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 			cpu = match[2]
 		}
 	}
+
+	return nil
 }
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pmd_perf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	vswitchd := appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+	vswitchd.Reply("dpif-netdev/pmd-perf-show", `
+Time: 13:28:45.342
+Measurement duration: 4376.587 s
+
+pmd thread numa_id 0 core_id 3:
+
+  Iterations:         184304299142  (2.35 us/it)
+  - Used TSC cycles:  997990776491190  ( 99.8 % of total cycles)
+  - idle iterations:  157612371830  (  7.3 % of used cycles)
+  - busy iterations:   26691927312  ( 92.7 % of used cycles)
+  Rx packets:         1553492231479  (3572 Kpps, 595 cycles/pkt)
+  Tx packets:         1553492231479  (3572 Kpps)
+
+pmd thread numa_id 1 core_id 21:
+
+  Iterations:                  100  (2.35 us/it)
+  Rx packets:                    7  (0 Kpps, 595 cycles/pkt)
+`)
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_pmd_rx_packets Number of packets received
+# TYPE ovs_pmd_rx_packets counter
+ovs_pmd_rx_packets{cpu="21",numa="1"} 7
+ovs_pmd_rx_packets{cpu="3",numa="0"} 1.553492231479e+12
+# HELP ovs_pmd_total_iterations Total number of iterations
+# TYPE ovs_pmd_total_iterations counter
+ovs_pmd_total_iterations{cpu="21",numa="1"} 100
+ovs_pmd_total_iterations{cpu="3",numa="0"} 1.84304299142e+11
+`), "ovs_pmd_rx_packets", "ovs_pmd_total_iterations")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,7 +5,9 @@ package pmd_rxq
 
 import (
 	"bufio"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,17 +15,30 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Collector struct{}
+const collectorName = "pmd-rxq"
+
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "pmd-rxq"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -46,12 +61,18 @@ var (
 	overheadRe = regexp.MustCompile(`^\s*overhead\s*:\s*([\d\.]+)\s*%$`)
 )
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
-	stats := getVswitchdPmdStat()
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	filters := config.Filters(c.Name())
+	logger := lib.Logger(c)
+	stats := getVswitchdPmdStat(logger, c.inst)
 
-	buf := appctl.OvsVSwitchd("dpif-netdev/pmd-rxq-show")
-	if buf == "" {
-		return
+	buf, err := c.inst.Appctl.OvsVSwitchd(ctx, "dpif-netdev/pmd-rxq-show")
+	if err != nil {
+		return fmt.Errorf("dpif-netdev/pmd-rxq-show: %w", err)
 	}
 
 	numa := ""
@@ -61,7 +82,7 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 	for scanner.Scan() {
 		line := scanner.Text()
 
-		if numa != "" && cpu != "" && config.MetricSets().Has(config.METRICS_PERF) {
+		if numa != "" && cpu != "" {
 			var val float64
 			var err error
 
@@ -69,36 +90,47 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 				if m[1] == "true" {
 					val = 1
 				}
-				ch <- prometheus.MustNewConstMetric(
-					isolatedMetric.Desc(), isolatedMetric.ValueType,
-					val, numa, cpu)
+				if lib.MetricEnabled(collectorName, &isolatedMetric) {
+					ch <- prometheus.MustNewConstMetric(
+						isolatedMetric.Desc(), isolatedMetric.ValueType,
+						val, numa, cpu)
+				}
 				continue
 			} else if m := rxqUsageRe.FindStringSubmatch(line); m != nil {
+				if !filters.Interface.Match(m[1]) {
+					continue
+				}
 				if m[3] == "enabled" {
 					val = 1
 				}
-				ch <- prometheus.MustNewConstMetric(
-					enabledMetric.Desc(), enabledMetric.ValueType,
-					val, numa, cpu, m[1], m[2])
+				if lib.MetricEnabled(collectorName, &enabledMetric) {
+					ch <- prometheus.MustNewConstMetric(
+						enabledMetric.Desc(), enabledMetric.ValueType,
+						val, numa, cpu, m[1], m[2])
+				}
 
 				val, err = strconv.ParseFloat(m[4], 64)
 				if err != nil {
-					log.Errf("pmd usage: %s", err)
+					logger.With("error", err).Errf("pmd usage")
 					continue
 				}
-				ch <- prometheus.MustNewConstMetric(
-					usageMetric.Desc(), usageMetric.ValueType,
-					val, numa, cpu, m[1], m[2])
+				if lib.MetricEnabled(collectorName, &usageMetric) {
+					ch <- prometheus.MustNewConstMetric(
+						usageMetric.Desc(), usageMetric.ValueType,
+						val, numa, cpu, m[1], m[2])
+				}
 				continue
 			} else if m := overheadRe.FindStringSubmatch(line); m != nil {
 				val, err = strconv.ParseFloat(m[1], 64)
 				if err != nil {
-					log.Errf("overhead: %s", err)
+					logger.With("error", err).Errf("overhead")
 					continue
 				}
-				ch <- prometheus.MustNewConstMetric(
-					overheadMetric.Desc(), overheadMetric.ValueType,
-					val, numa, cpu)
+				if lib.MetricEnabled(collectorName, &overheadMetric) {
+					ch <- prometheus.MustNewConstMetric(
+						overheadMetric.Desc(), overheadMetric.ValueType,
+						val, numa, cpu)
+				}
 				continue
 			}
 		}
@@ -112,13 +144,13 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 			if !ok {
 				continue
 			}
-			if config.MetricSets().Has(ctxtSwitchesMetric.Set) {
+			if lib.MetricEnabled(collectorName, &ctxtSwitchesMetric) {
 				ch <- prometheus.MustNewConstMetric(
 					ctxtSwitchesMetric.Desc(),
 					ctxtSwitchesMetric.ValueType,
 					float64(stat.ctxSwitches), numa, cpu)
 			}
-			if config.MetricSets().Has(nonVolCtxtSwitchesMetric.Set) {
+			if lib.MetricEnabled(collectorName, &nonVolCtxtSwitchesMetric) {
 				ch <- prometheus.MustNewConstMetric(
 					nonVolCtxtSwitchesMetric.Desc(),
 					nonVolCtxtSwitchesMetric.ValueType,
@@ -126,6 +158,8 @@ func (Collector) Collect(ch chan<- prometheus.Metric) {
 			}
 		}
 	}
+
+	return nil
 }
 
 type pmdstat struct {
@@ -178,23 +212,23 @@ func parseStatus(path string) (pmdstat, error) {
 	return stat, nil
 }
 
-func getVswitchdPmdStat() map[uint64]pmdstat {
-	pidfile := filepath.Join(config.OvsRundir(), "ovs-vswitchd.pid")
+func getVswitchdPmdStat(logger *log.Logger, inst *instance.Instance) map[uint64]pmdstat {
+	pidfile := filepath.Join(inst.OvsRundir, "ovs-vswitchd.pid")
 	f, err := os.Open(pidfile)
 	if err != nil {
-		log.Errf("open(%s): %s", pidfile, err)
+		logger.With("error", err).Errf("open(%s)", pidfile)
 		return nil
 	}
 	defer f.Close()
 	buf, err := io.ReadAll(f)
 	if err != nil {
-		log.Errf("read(%s): %s", pidfile, err)
+		logger.With("error", err).Errf("read(%s)", pidfile)
 		return nil
 	}
-	tasks := filepath.Join(config.OvsProcdir(), strings.TrimSpace(string(buf)), "task")
+	tasks := filepath.Join(inst.OvsProcdir, strings.TrimSpace(string(buf)), "task")
 	entries, err := os.ReadDir(tasks)
 	if err != nil {
-		log.Errf("readdir(%s): %s", tasks, err)
+		logger.With("error", err).Errf("readdir(%s)", tasks)
 		return nil
 	}
 
@@ -205,7 +239,7 @@ func getVswitchdPmdStat() map[uint64]pmdstat {
 			stat, err := parseStatus(filepath.Join(tasks, e.Name(), "status"))
 			if err != nil {
 				if !errors.Is(err, errNotPmd) {
-					log.Errf("status(%s): %s", e.Name(), err)
+					logger.With("error", err).Errf("status(%s)", e.Name())
 				}
 				continue
 			}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pmd_rxq
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Create /proc/<pid>/task/<tid>/status for a thread.
+func writeTaskStatus(t *testing.T, procdir string, pid, tid int, status string) {
+	t.Helper()
+	dir := filepath.Join(procdir, strconv.Itoa(pid), "task", strconv.Itoa(tid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	vswitchd := appctltest.NewServer(t, inst.OvsRundir, "ovs-vswitchd")
+	vswitchd.Reply("dpif-netdev/pmd-rxq-show", `pmd thread numa_id 0 core_id 3:
+  isolated : true
+  port: dpdk0               queue-id:  0 (enabled)   pmd usage: 43 %
+  port: vhu-30              queue-id:  1 (disabled)  pmd usage:  0 %
+  overhead:  11 %
+`)
+	writeTaskStatus(t, inst.OvsProcdir, vswitchd.Pid, 100, `Name:	ovs-vswitchd
+Cpus_allowed_list:	0
+`)
+	writeTaskStatus(t, inst.OvsProcdir, vswitchd.Pid, 101, `Name:	pmd-c03/id:101
+Cpus_allowed_list:	3
+Mems_allowed_list:	0
+voluntary_ctxt_switches:	12
+nonvoluntary_ctxt_switches:	4
+`)
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_pmd_context_switches Number of voluntary context switches per PMD thread.
+# TYPE ovs_pmd_context_switches counter
+ovs_pmd_context_switches{cpu="3",numa="0"} 12
+# HELP ovs_pmd_cpu_isolated 1 or 0 whether the CPU is excluded from automatic Rxq balancing.
+# TYPE ovs_pmd_cpu_isolated gauge
+ovs_pmd_cpu_isolated{cpu="3",numa="0"} 1
+# HELP ovs_pmd_cpu_overhead Percentage of CPU cycles not related to one specific Rxq.
+# TYPE ovs_pmd_cpu_overhead gauge
+ovs_pmd_cpu_overhead{cpu="3",numa="0"} 11
+# HELP ovs_pmd_nonvol_context_switches Number of non-voluntary context switches per PMD thread.
+# TYPE ovs_pmd_nonvol_context_switches counter
+ovs_pmd_nonvol_context_switches{cpu="3",numa="0"} 4
+# HELP ovs_pmd_rxq_enabled 1 or 0 whether a vhost-user Rxq is enabled by a guest.
+# TYPE ovs_pmd_rxq_enabled gauge
+ovs_pmd_rxq_enabled{cpu="3",interface="dpdk0",numa="0",rxq="0"} 1
+ovs_pmd_rxq_enabled{cpu="3",interface="vhu-30",numa="0",rxq="1"} 0
+# HELP ovs_pmd_rxq_usage Percentage of CPU cycles used to process packets from one Rxq.
+# TYPE ovs_pmd_rxq_usage gauge
+ovs_pmd_rxq_usage{cpu="3",interface="dpdk0",numa="0",rxq="0"} 43
+ovs_pmd_rxq_usage{cpu="3",interface="vhu-30",numa="0",rxq="1"} 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,20 +5,32 @@ package vswitch
 
 import (
 	"context"
-	"time"
+	"fmt"
 
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
-	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Collector struct{}
+const collectorName = "vswitch"
+
+type Collector struct {
+	inst *instance.Instance
+}
+
+// New returns a collector reading its metrics from an instance.
+func New(inst *instance.Instance) *Collector {
+	return &Collector{inst: inst}
+}
+
+func (c *Collector) Instance() *instance.Instance {
+	return c.inst
+}
 
 func (Collector) Name() string {
-	return "vswitch"
+	return collectorName
 }
 
 func (Collector) Metrics() []lib.Metric {
@@ -33,21 +45,27 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	lib.DescribeEnabledMetrics(c, ch)
 }
 
-func (Collector) Collect(ch chan<- prometheus.Metric) {
-	if !config.MetricSets().Has(config.METRICS_BASE) {
-		return
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	lib.Collect(c, ch)
+}
+
+func (c *Collector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	if !lib.AnyMetricEnabled(collectorName, c.Metrics()) {
+		return nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
 	var vswitch ovs.OpenvSwitch
-	err := ovsdb.Get(ctx, &vswitch)
+	err := ovsdb.Get(ctx, c.inst.Ovsdb, &vswitch)
 	if err != nil {
-		log.Errf("OvsdbGet(vswitch): %s", err)
-		return
+		return fmt.Errorf("OvsdbGet(vswitch): %w", err)
 	}
 
 	for _, m := range metrics {
+		if !lib.MetricEnabled(collectorName, &m.Metric) {
+			continue
+		}
 		value, labels := m.GetValue(&vswitch)
 		ch <- prometheus.MustNewConstMetric(m.Desc(), m.ValueType, value, labels...)
 	}
+
+	return nil
 }
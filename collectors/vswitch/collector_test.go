@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package vswitch
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovsdbtest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestCollector(t *testing.T) {
+	inst := instancetest.New(t)
+	db := ovsdbtest.NewServer(t, inst.OvsRundir)
+	db.Insert(t, &ovs.OpenvSwitch{
+		OVSVersion:      ptr("3.3.1"),
+		DpdkVersion:     ptr("DPDK 23.11.0"),
+		DbVersion:       ptr("8.5.0"),
+		DpdkInitialized: true,
+	})
+
+	err := testutil.CollectAndCompare(New(inst), strings.NewReader(`
+# HELP ovs_build_info Version and library from which OVS binaries were built.
+# TYPE ovs_build_info gauge
+ovs_build_info{db_version="8.5.0",dpdk_version="DPDK 23.11.0",ovs_version="3.3.1"} 1
+# HELP ovs_dpdk_initialized Has the DPDK subsystem been initialized.
+# TYPE ovs_dpdk_initialized gauge
+ovs_dpdk_initialized 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorNoDatabase(t *testing.T) {
+	c := New(instancetest.New(t))
+	if err := c.CollectWithContext(context.Background(), nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
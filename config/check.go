@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseStrict works like Parse but also rejects unknown keys in the YAML
+// configuration file.
+func ParseStrict() error {
+	c, err := parse(true)
+	if err != nil {
+		return err
+	}
+	current.Store(c)
+	return nil
+}
+
+func checkReadable(option, path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", option, err)
+	}
+	f.Close()
+	return nil
+}
+
+func checkDir(option, path string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", option, err)
+	}
+	if !st.IsDir() {
+		return fmt.Errorf("%s: %s: not a directory", option, path)
+	}
+	return nil
+}
+
+func (t *ClientTLS) checkFiles(prefix string) []error {
+	return []error{
+		checkReadable(prefix+".ca", t.Ca),
+		checkReadable(prefix+".cert", t.Cert),
+		checkReadable(prefix+".key", t.Key),
+	}
+}
+
+func (c *Credentials) checkFiles(prefix string) []error {
+	errs := []error{checkReadable(prefix+".bearer-token-file", c.BearerTokenFile)}
+	if c.BasicAuth != nil {
+		errs = append(errs,
+			checkReadable(prefix+".basic-auth.password-file", c.BasicAuth.PasswordFile))
+	}
+	return errs
+}
+
+// CheckPaths verifies that the files referenced by the active configuration
+// are readable and that the runtime directories exist. All errors are
+// returned.
+func CheckPaths() error {
+	c := current.Load()
+
+	errs := []error{
+		checkReadable("tls-cert", c.TlsCert),
+		checkReadable("tls-key", c.TlsKey),
+		checkReadable("tls-client-ca", c.TlsClientCa),
+		checkReadable("auth-users-file", c.AuthUsersFile),
+	}
+	for _, i := range c.instances {
+		prefix := ""
+		if i.Name != "" {
+			prefix = fmt.Sprintf("instances[%s].", i.Name)
+		}
+		errs = append(errs,
+			checkDir(prefix+"ovs-rundir", i.OvsRundir),
+			checkDir(prefix+"ovn-rundir", i.OvnRundir),
+			checkDir(prefix+"ovsdb-rundir", i.OvsdbRundir),
+			checkDir(prefix+"ovs-procdir", i.OvsProcdir),
+		)
+	}
+	if c.RemoteWrite.Url != "" {
+		errs = append(errs, c.RemoteWrite.Tls.checkFiles("remote-write.tls")...)
+		errs = append(errs, c.RemoteWrite.Credentials.checkFiles("remote-write")...)
+	}
+	if c.Otlp.Endpoint != "" {
+		errs = append(errs, c.Otlp.Tls.checkFiles("otlp.tls")...)
+		errs = append(errs, c.Otlp.Credentials.checkFiles("otlp")...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// CollectorReferences returns the collector names used in the active
+// configuration, indexed by option name.
+func CollectorReferences() map[string][]string {
+	c := current.Load()
+	return map[string][]string{
+		"collectors":            c.Collectors,
+		"collector-metric-sets": slices.Sorted(maps.Keys(c.CollectorSets)),
+		"filters":               slices.Sorted(maps.Keys(c.Filters)),
+		"poll-intervals":        slices.Sorted(maps.Keys(c.PollIntervals)),
+	}
+}
+
+const redacted = "<redacted>"
+
+func (c *Credentials) redact() {
+	if c.BearerToken != "" {
+		c.BearerToken = redacted
+	}
+	if c.BasicAuth != nil && c.BasicAuth.Password != "" {
+		auth := *c.BasicAuth
+		auth.Password = redacted
+		c.BasicAuth = &auth
+	}
+}
+
+func redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return headers
+	}
+	res := make(map[string]string, len(headers))
+	for k := range headers {
+		res[k] = redacted
+	}
+	return res
+}
+
+// Dump writes the active configuration in YAML format, after merging the
+// configuration file and the environment overrides. Passwords, tokens and
+// HTTP header values are redacted.
+func Dump(w io.Writer) error {
+	c := *current.Load()
+
+	c.AuthUsers = slices.Clone(c.AuthUsers)
+	for i := range c.AuthUsers {
+		c.AuthUsers[i].Password = redacted
+	}
+	c.RemoteWrite.Credentials.redact()
+	c.Otlp.Credentials.redact()
+	c.Otlp.Headers = redactHeaders(c.Otlp.Headers)
+	if len(c.Instances) > 0 {
+		c.Instances = nil
+		for _, i := range c.instances {
+			c.Instances = append(c.Instances, *i)
+		}
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
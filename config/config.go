@@ -4,12 +4,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log/syslog"
+	"io"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/filewatch"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
 	"gopkg.in/yaml.v3"
 )
@@ -57,70 +62,212 @@ type user struct {
 	Password string
 }
 
+// Client certificate authentication modes.
+type ClientAuth string
+
+const (
+	// Client certificates are not requested.
+	CLIENT_AUTH_NONE ClientAuth = "none"
+	// A client certificate is requested but not required. If present, it
+	// must be signed by tls-client-ca.
+	CLIENT_AUTH_REQUEST ClientAuth = "request"
+	// A client certificate is required and must be signed by tls-client-ca.
+	// Invalid certificates are rejected with an HTTP error.
+	CLIENT_AUTH_REQUIRE ClientAuth = "require"
+	// A client certificate is required and must be signed by tls-client-ca.
+	// Invalid certificates are rejected during the TLS handshake.
+	CLIENT_AUTH_VERIFY ClientAuth = "verify"
+)
+
 type conf struct {
-	HttpListen  string            `yaml:"http-listen" env:"OPENSTACK_NETWORK_EXPORTER_HTTP_LISTEN"`
-	HttpPath    string            `yaml:"http-path" env:"OPENSTACK_NETWORK_EXPORTER_HTTP_PATH"`
-	TlsCert     string            `yaml:"tls-cert" env:"OPENSTACK_NETWORK_EXPORTER_TLS_CERT"`
-	TlsKey      string            `yaml:"tls-key" env:"OPENSTACK_NETWORK_EXPORTER_TLS_KEY"`
-	AuthUsers   []user            `yaml:"auth-users"`
-	users       map[string]string `yaml:"-"`
-	OvsRundir   string            `yaml:"ovs-rundir" env:"OPENSTACK_NETWORK_EXPORTER_OVS_RUNDIR"`
-	OvnRundir   string            `yaml:"ovn-rundir" env:"OPENSTACK_NETWORK_EXPORTER_OVN_RUNDIR"`
-	OvsdbRundir string            `yaml:"ovsdb-rundir" env:"OPENSTACK_NETWORK_EXPORTER_OVSDB_RUNDIR"`
-	OvsProcdir  string            `yaml:"ovs-procdir" env:"OPENSTACK_NETWORK_EXPORTER_OVS_PROCDIR"`
-	LogLevel    string            `yaml:"log-level" env:"OPENSTACK_NETWORK_EXPORTER_LOG_LEVEL"`
-	logLevel    syslog.Priority   `yaml:"-"`
-	Collectors  []string          `yaml:"collectors"`
-	MetricSets  []string          `yaml:"metric-sets"`
-	metricSets  MetricSet         `yaml:"-"`
-	IntBrdNam   string            `yaml:"br-int-name" env:"OPENSTACK_NETWORK_EXPORTER_BR_INT_NAME"`
-}
-
-var c = conf{
-	HttpListen:  ":1981",
-	HttpPath:    "/metrics",
-	OvsRundir:   "/run/openvswitch",
-	OvnRundir:   "/run/ovn",
-	OvsdbRundir: "/run/ovn",
-	OvsProcdir:  "/proc",
-	LogLevel:    "notice",
-	users:       make(map[string]string),
-	IntBrdNam:   "br-int",
-}
-
-func HttpListen() string           { return c.HttpListen }
-func HttpPath() string             { return c.HttpPath }
-func TlsCert() string              { return c.TlsCert }
-func TlsKey() string               { return c.TlsKey }
-func OvsRundir() string            { return c.OvsRundir }
-func OvnRundir() string            { return c.OvnRundir }
-func OvsdbRundir() string          { return c.OvsdbRundir }
-func OvsProcdir() string           { return c.OvsProcdir }
-func Collectors() []string         { return c.Collectors }
-func LogLevel() syslog.Priority    { return c.logLevel }
-func AuthUsers() map[string]string { return c.users }
-func MetricSets() MetricSet        { return c.metricSets }
-func IntBrdNam() string            { return c.IntBrdNam }
+	HttpListen        ListenAddrs               `yaml:"http-listen" env:"OPENSTACK_NETWORK_EXPORTER_HTTP_LISTEN"`
+	listen            []ListenAddr              `yaml:"-"`
+	HttpPath          string                    `yaml:"http-path" env:"OPENSTACK_NETWORK_EXPORTER_HTTP_PATH"`
+	TlsCert           string                    `yaml:"tls-cert" env:"OPENSTACK_NETWORK_EXPORTER_TLS_CERT"`
+	TlsKey            string                    `yaml:"tls-key" env:"OPENSTACK_NETWORK_EXPORTER_TLS_KEY"`
+	TlsClientCa       string                    `yaml:"tls-client-ca" env:"OPENSTACK_NETWORK_EXPORTER_TLS_CLIENT_CA"`
+	TlsClientAuth     ClientAuth                `yaml:"tls-client-auth" env:"OPENSTACK_NETWORK_EXPORTER_TLS_CLIENT_AUTH"`
+	TlsClientAllowed  []string                  `yaml:"tls-client-allowed-names"`
+	AuthUsers         []user                    `yaml:"auth-users"`
+	AuthUsersFile     string                    `yaml:"auth-users-file" env:"OPENSTACK_NETWORK_EXPORTER_AUTH_USERS_FILE"`
+	users             map[string]string         `yaml:"-"`
+	OvsRundir         string                    `yaml:"ovs-rundir" env:"OPENSTACK_NETWORK_EXPORTER_OVS_RUNDIR"`
+	OvnRundir         string                    `yaml:"ovn-rundir" env:"OPENSTACK_NETWORK_EXPORTER_OVN_RUNDIR"`
+	OvsdbRundir       string                    `yaml:"ovsdb-rundir" env:"OPENSTACK_NETWORK_EXPORTER_OVSDB_RUNDIR"`
+	OvsProcdir        string                    `yaml:"ovs-procdir" env:"OPENSTACK_NETWORK_EXPORTER_OVS_PROCDIR"`
+	LogLevel          string                    `yaml:"log-level" env:"OPENSTACK_NETWORK_EXPORTER_LOG_LEVEL"`
+	logLevels         *log.Levels               `yaml:"-"`
+	LogFormat         string                    `yaml:"log-format" env:"OPENSTACK_NETWORK_EXPORTER_LOG_FORMAT"`
+	Collectors        []string                  `yaml:"collectors"`
+	MetricSets        []string                  `yaml:"metric-sets"`
+	metricSets        MetricSet                 `yaml:"-"`
+	CollectorSets     map[string][]string       `yaml:"collector-metric-sets"`
+	collectorSets     map[string]MetricSet      `yaml:"-"`
+	EnableMetrics     []string                  `yaml:"enable-metrics"`
+	DisableMetrics    []string                  `yaml:"disable-metrics"`
+	IntBrdNam         string                    `yaml:"br-int-name" env:"OPENSTACK_NETWORK_EXPORTER_BR_INT_NAME"`
+	ReadinessBackends []string                  `yaml:"readiness-backends"`
+	RelabelRules      []RelabelRule             `yaml:"metric-relabel-configs"`
+	Filters           map[string]*ObjectFilters `yaml:"filters"`
+	PollDefault       time.Duration             `yaml:"poll-interval"`
+	PollIntervals     map[string]time.Duration  `yaml:"poll-intervals"`
+	ScrapeTimeout     time.Duration             `yaml:"scrape-timeout"`
+	Unixctl           Unixctl                   `yaml:"unixctl"`
+	CustomCommands    []CustomCommand           `yaml:"custom-commands"`
+	RemoteWrite       RemoteWrite               `yaml:"remote-write"`
+	Otlp              Otlp                      `yaml:"otlp"`
+	ConstLabels       map[string]string         `yaml:"const-labels"`
+	ExternalIdLabels  map[string]string         `yaml:"external-id-labels"`
+	Instances         []Instance                `yaml:"instances"`
+	instances         []*Instance               `yaml:"-"`
+}
 
-func Parse() error {
+func defaults() *conf {
+	return &conf{
+		HttpListen:    ListenAddrs{":1981"},
+		HttpPath:      "/metrics",
+		OvsRundir:     "/run/openvswitch",
+		OvnRundir:     "/run/ovn",
+		OvsdbRundir:   "/run/ovn",
+		OvsProcdir:    "/proc",
+		LogLevel:      "notice",
+		LogFormat:     log.FORMAT_TEXT,
+		users:         make(map[string]string),
+		IntBrdNam:     "br-int",
+		ScrapeTimeout: 2 * time.Second,
+		Unixctl:       unixctlDefaults(),
+		RemoteWrite:   remoteWriteDefaults(),
+		Otlp:          otlpDefaults(),
+	}
+}
+
+// The active configuration. It is replaced as a whole when the configuration
+// is reloaded so that readers never see a partially parsed state.
+var current atomic.Pointer[conf]
+
+func init() {
+	c := defaults()
+	c.logLevels, _ = log.ParseLevels(c.LogLevel)
+	c.metricSets = METRICS_DEFAULT
+	_ = parseListen(c)
+	_ = parseInstances(c)
+	current.Store(c)
+}
+
+func HttpListen() []ListenAddr     { return current.Load().listen }
+func HttpPath() string             { return current.Load().HttpPath }
+func TlsCert() string              { return current.Load().TlsCert }
+func TlsKey() string               { return current.Load().TlsKey }
+func TlsClientCa() string          { return current.Load().TlsClientCa }
+func TlsClientAuth() ClientAuth    { return current.Load().TlsClientAuth }
+func TlsClientAllowed() []string   { return current.Load().TlsClientAllowed }
+func OvsRundir() string            { return current.Load().OvsRundir }
+func OvnRundir() string            { return current.Load().OvnRundir }
+func OvsdbRundir() string          { return current.Load().OvsdbRundir }
+func OvsProcdir() string           { return current.Load().OvsProcdir }
+func Collectors() []string         { return current.Load().Collectors }
+func LogLevels() *log.Levels       { return current.Load().logLevels }
+func LogFormat() string            { return current.Load().LogFormat }
+func AuthUsers() map[string]string { return current.Load().users }
+func AuthUsersFile() string        { return current.Load().AuthUsersFile }
+func MetricSets() MetricSet        { return current.Load().metricSets }
+func EnableMetrics() []string      { return current.Load().EnableMetrics }
+func DisableMetrics() []string     { return current.Load().DisableMetrics }
+func IntBrdNam() string            { return current.Load().IntBrdNam }
+func ReadinessBackends() []string  { return current.Load().ReadinessBackends }
+func RelabelRules() []RelabelRule  { return current.Load().RelabelRules }
+
+// RemoteWriteSettings returns the remote_write push settings. The returned value
+// changes identity when the configuration is reloaded.
+func RemoteWriteSettings() *RemoteWrite { return &current.Load().RemoteWrite }
+
+// OtlpSettings returns the OTLP export settings. The returned value changes
+// identity when the configuration is reloaded.
+func OtlpSettings() *Otlp { return &current.Load().Otlp }
+
+// PollInterval returns the background polling interval of a collector. Zero
+// means that the collector is scraped synchronously.
+func PollInterval(collector string) time.Duration {
+	c := current.Load()
+	if interval, ok := c.PollIntervals[collector]; ok {
+		return interval
+	}
+	return c.PollDefault
+}
+
+// ScrapeTimeout returns the maximum time spent querying the backends of a
+// collector during one scrape.
+func ScrapeTimeout() time.Duration { return current.Load().ScrapeTimeout }
+
+// UnixctlSettings returns the settings of the unixctl client. The returned
+// value changes identity when the configuration is reloaded.
+func UnixctlSettings() *Unixctl { return &current.Load().Unixctl }
+
+// Path returns the location of the YAML configuration file and whether it
+// was explicitly set via the environment.
+func Path() (string, bool) {
 	path, configInEnv := os.LookupEnv("OPENSTACK_NETWORK_EXPORTER_YAML")
 	if !configInEnv {
 		path = defaultConfigPath
 	}
+	return path, configInEnv
+}
+
+// Parse the YAML configuration file and the environment overrides. On
+// success, the new configuration replaces the active one. On error, the
+// active configuration is left untouched. This can be called multiple times
+// to reload the configuration.
+func Parse() error {
+	c, err := parse(false)
+	if err != nil {
+		return err
+	}
+	current.Store(c)
+	return nil
+}
+
+// Config is a parsed configuration which is not necessarily active.
+type Config struct {
+	c *conf
+}
+
+// Load parses the YAML configuration file and the environment overrides
+// without activating the result.
+func Load() (*Config, error) {
+	c, err := parse(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{c: c}, nil
+}
+
+// Activate makes cfg the active configuration and returns the previously
+// active one, so that it can be restored.
+func Activate(cfg *Config) *Config {
+	return &Config{c: current.Swap(cfg.c)}
+}
+
+// Parse the configuration without activating it. If strict is true, unknown
+// YAML keys are rejected.
+func parse(strict bool) (*conf, error) {
+	c := defaults()
+	path, configInEnv := Path()
 
 	// parse yaml config file
 	if file, err := os.Open(path); err == nil {
+		defer file.Close()
 		dec := yaml.NewDecoder(file)
-		if err = dec.Decode(&c); err != nil {
-			return err
+		dec.KnownFields(strict)
+		if err = dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
 		}
 	} else if configInEnv {
-		return err
+		return nil, err
 	}
 
 	// override with values from environment
-	typ := reflect.TypeOf(c)
-	val := reflect.ValueOf(c)
+	typ := reflect.TypeOf(*c)
+	val := reflect.ValueOf(c).Elem()
 	for i := 0; i < typ.NumField(); i++ {
 		fieldVal := val.Field(i)
 		fieldType := typ.Field(i)
@@ -132,24 +279,214 @@ func Parse() error {
 		if !found {
 			continue
 		}
-		fieldVal.SetString(envValue)
+		if fieldVal.Kind() == reflect.Slice {
+			// comma separated list
+			list := strings.Split(envValue, ",")
+			fieldVal.Set(reflect.ValueOf(list).Convert(fieldType.Type))
+		} else {
+			fieldVal.SetString(envValue)
+		}
 	}
 
 	// parse complex values
-	for _, user := range c.AuthUsers {
+	users := c.AuthUsers
+	if c.AuthUsersFile != "" {
+		fileUsers, err := loadUsersFile(c.AuthUsersFile)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, fileUsers...)
+	}
+	for _, user := range users {
 		c.users[user.Name] = user.Password
 	}
-	if prio, err := log.ParseLogLevel(c.LogLevel); err != nil {
-		return err
+	if levels, err := log.ParseLevels(c.LogLevel); err != nil {
+		return nil, err
 	} else {
-		c.logLevel = prio
+		c.logLevels = levels
+	}
+	if err := log.CheckFormat(c.LogFormat); err != nil {
+		return nil, err
 	}
 	if sets, err := ParseMetricSets(c.MetricSets); err != nil {
-		return err
+		return nil, err
 	} else {
 		c.metricSets = sets
 	}
+	if err := parseCollectorSets(c); err != nil {
+		return nil, err
+	}
+	if err := parseClientAuth(c); err != nil {
+		return nil, err
+	}
+	if err := parseListen(c); err != nil {
+		return nil, err
+	}
+	if err := checkReadinessBackends(c.ReadinessBackends); err != nil {
+		return nil, err
+	}
+	if err := ParseRelabelRules(c.RelabelRules); err != nil {
+		return nil, err
+	}
+	if err := parseFilters(c.Filters); err != nil {
+		return nil, err
+	}
+	if err := parseCustomCommands(c.CustomCommands); err != nil {
+		return nil, err
+	}
+	if err := parseConstLabels(c); err != nil {
+		return nil, err
+	}
+	if err := parseInstances(c); err != nil {
+		return nil, err
+	}
+	if c.PollDefault < 0 {
+		return nil, fmt.Errorf("poll-interval: invalid negative value: %s", c.PollDefault)
+	}
+	for name, interval := range c.PollIntervals {
+		if interval < 0 {
+			return nil, fmt.Errorf("poll-intervals: %s: invalid negative value: %s", name, interval)
+		}
+	}
+	if c.ScrapeTimeout <= 0 {
+		return nil, fmt.Errorf("scrape-timeout: must be positive: %s", c.ScrapeTimeout)
+	}
+	if err := c.Unixctl.check(); err != nil {
+		return nil, fmt.Errorf("unixctl: %w", err)
+	}
+	if err := c.RemoteWrite.check(); err != nil {
+		return nil, fmt.Errorf("remote-write: %w", err)
+	}
+	if err := c.Otlp.check(); err != nil {
+		return nil, fmt.Errorf("otlp: %w", err)
+	}
+	switch c.HttpPath {
+	case "/healthz", "/readyz":
+		return nil, fmt.Errorf("http-path %q is reserved for health checks", c.HttpPath)
+	}
+
+	return c, nil
+}
+
+// Names of the backends which can be listed in readiness-backends.
+var readinessBackends = []string{
+	"ovsdb", "openflow",
+	"ovs-vswitchd", "ovn-controller", "ovn-northd", "ovsdb-server",
+}
+
+func checkReadinessBackends(names []string) error {
+	for _, name := range names {
+		if !slices.Contains(readinessBackends, name) {
+			return fmt.Errorf("invalid readiness backend: %q", name)
+		}
+	}
+	return nil
+}
+
+// Load a list of users from a YAML file with the same format as auth-users.
+func loadUsersFile(path string) ([]user, error) {
+	var users []user
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(buf, &users); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return users, nil
+}
+
+func parseClientAuth(c *conf) error {
+	if c.TlsCert == "" || c.TlsKey == "" {
+		if c.TlsClientCa != "" || len(c.TlsClientAllowed) > 0 ||
+			(c.TlsClientAuth != "" && c.TlsClientAuth != CLIENT_AUTH_NONE) {
+			return fmt.Errorf("tls-client-ca, tls-client-auth and " +
+				"tls-client-allowed-names require tls-cert and tls-key")
+		}
+	}
+	switch c.TlsClientAuth {
+	case "":
+		if c.TlsClientCa != "" {
+			c.TlsClientAuth = CLIENT_AUTH_VERIFY
+		} else {
+			c.TlsClientAuth = CLIENT_AUTH_NONE
+		}
+	case CLIENT_AUTH_NONE:
+	case CLIENT_AUTH_REQUEST, CLIENT_AUTH_REQUIRE, CLIENT_AUTH_VERIFY:
+		if c.TlsClientCa == "" {
+			return fmt.Errorf("tls-client-auth %q requires tls-client-ca", c.TlsClientAuth)
+		}
+	default:
+		return fmt.Errorf("invalid tls-client-auth mode: %q", c.TlsClientAuth)
+	}
+	if len(c.TlsClientAllowed) > 0 && c.TlsClientAuth == CLIENT_AUTH_NONE {
+		return fmt.Errorf("tls-client-allowed-names requires tls-client-auth")
+	}
+	return nil
+}
+
+// Return the files from which the configuration is read.
+func watchedFiles() []string {
+	path, _ := Path()
+	files := []string{path}
+	if f := AuthUsersFile(); f != "" {
+		files = append(files, f)
+	}
+	return files
+}
+
+// Watch polls the configuration file and the auth-users-file every interval
+// and calls fn whenever one of them is created, removed or modified. Watch
+// never returns.
+func Watch(interval time.Duration, fn func()) {
+	filewatch.Poll(interval, watchedFiles, func(changed []string) {
+		log.Debugf("files changed: %s", strings.Join(changed, ", "))
+		fn()
+	})
+}
 
+// Return the metric sets enabled for a collector. Unless overridden in
+// collector-metric-sets, these are the globally enabled metric sets.
+func (c *conf) collectorMetricSets(collector string) MetricSet {
+	if sets, ok := c.collectorSets[collector]; ok {
+		return sets
+	}
+	return c.metricSets
+}
+
+// Return true if a metric of a collector must be exported. Metrics listed in
+// disable-metrics are never exported. Metrics listed in enable-metrics are
+// exported even if their set is not enabled for the collector.
+func MetricEnabled(collector string, name string, set MetricSet) bool {
+	c := current.Load()
+	if slices.Contains(c.DisableMetrics, name) {
+		return false
+	}
+	if slices.Contains(c.EnableMetrics, name) {
+		return true
+	}
+	return c.collectorMetricSets(collector).Has(set)
+}
+
+func parseCollectorSets(c *conf) error {
+	c.collectorSets = make(map[string]MetricSet)
+	for name, names := range c.CollectorSets {
+		if len(names) == 0 {
+			return fmt.Errorf("collector-metric-sets: %s: empty list", name)
+		}
+		sets, err := ParseMetricSets(names)
+		if err != nil {
+			return fmt.Errorf("collector-metric-sets: %s: %w", name, err)
+		}
+		c.collectorSets[name] = sets
+	}
+	for _, name := range c.EnableMetrics {
+		if slices.Contains(c.DisableMetrics, name) {
+			return fmt.Errorf("metric %q is both enabled and disabled", name)
+		}
+	}
 	return nil
 }
 
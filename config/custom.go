@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/prometheus/common/model"
+)
+
+// Parsers of the output of custom unixctl commands. Each one splits the
+// output into records of named string fields.
+type CustomParser string
+
+const (
+	// Each match of a regular expression is a record. Its fields are the
+	// named groups of the expression.
+	CUSTOM_PARSE_REGEX CustomParser = "regex"
+	// The whole output is a single record. Its fields are the "key: value"
+	// or "key:value" pairs.
+	CUSTOM_PARSE_KEY_VALUE CustomParser = "key-value"
+	// Each counter of the coverage/show table is a record with name and
+	// total fields.
+	CUSTOM_PARSE_COVERAGE CustomParser = "coverage"
+)
+
+// A metric exported from the records of a custom unixctl command.
+type CustomMetric struct {
+	Name string `yaml:"name"`
+	// Either gauge or counter.
+	Type string `yaml:"type"`
+	Help string `yaml:"help"`
+	// Metric set which must be enabled for the metric to be exported.
+	Set string    `yaml:"set"`
+	set MetricSet `yaml:"-"`
+	// Field of the records holding the metric value. Records without this
+	// field or with a non-numeric value are skipped.
+	Value string `yaml:"value"`
+	// Fields of the records holding the label values, indexed by label
+	// name.
+	Labels     map[string]string `yaml:"labels"`
+	labelNames []string          `yaml:"-"`
+}
+
+// MetricSet returns the parsed set of the metric.
+func (m *CustomMetric) MetricSet() MetricSet { return m.set }
+
+// LabelNames returns the sorted names of the metric labels.
+func (m *CustomMetric) LabelNames() []string { return m.labelNames }
+
+// A unixctl command run by the custom collector.
+type CustomCommand struct {
+	// Name of the daemon, as with the -t option of ovs-appctl, or path of
+	// its unixctl socket.
+	Daemon  string       `yaml:"daemon"`
+	Command string       `yaml:"command"`
+	Args    []string     `yaml:"args"`
+	Parse   CustomParser `yaml:"parse"`
+	// Only used with the regex parser. It is matched in multi-line mode.
+	Regex   string         `yaml:"regex"`
+	regex   *regexp.Regexp `yaml:"-"`
+	Metrics []CustomMetric `yaml:"metrics"`
+}
+
+// Regexp returns the compiled regex of the command, if any.
+func (c *CustomCommand) Regexp() *regexp.Regexp { return c.regex }
+
+// Return the fields of the records produced by the command parser. A nil
+// slice means that the fields are only known once the output is parsed.
+func (c *CustomCommand) fields() ([]string, error) {
+	if c.Parse != CUSTOM_PARSE_REGEX && c.Regex != "" {
+		return nil, fmt.Errorf("regex: only supported with parse: %s", CUSTOM_PARSE_REGEX)
+	}
+
+	switch c.Parse {
+	case CUSTOM_PARSE_REGEX:
+		if c.Regex == "" {
+			return nil, errors.New("regex: missing value")
+		}
+		re, err := regexp.Compile("(?m)" + c.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("regex: %w", err)
+		}
+		c.regex = re
+		var fields []string
+		for _, name := range re.SubexpNames() {
+			if name != "" {
+				fields = append(fields, name)
+			}
+		}
+		if len(fields) == 0 {
+			return nil, errors.New("regex: no named groups")
+		}
+		return fields, nil
+	case CUSTOM_PARSE_KEY_VALUE:
+		return nil, nil
+	case CUSTOM_PARSE_COVERAGE:
+		return []string{"name", "total"}, nil
+	default:
+		return nil, fmt.Errorf("parse: invalid value: %q", c.Parse)
+	}
+}
+
+func (m *CustomMetric) check(cmd *CustomCommand, fields []string) error {
+	checkField := func(field string) error {
+		if field == "" {
+			return errors.New("empty field name")
+		}
+		if fields != nil && !slices.Contains(fields, field) {
+			return fmt.Errorf("unknown field: %q", field)
+		}
+		return nil
+	}
+
+	if !model.LegacyValidation.IsValidMetricName(m.Name) {
+		return fmt.Errorf("invalid metric name: %q", m.Name)
+	}
+	switch m.Type {
+	case "":
+		m.Type = "gauge"
+	case "gauge", "counter":
+	default:
+		return fmt.Errorf("type: invalid value: %q", m.Type)
+	}
+	if err := checkField(m.Value); err != nil {
+		return fmt.Errorf("value: %w", err)
+	}
+	if m.Help == "" {
+		m.Help = fmt.Sprintf("Value of %s in the output of %s %s.", m.Value, cmd.Daemon, cmd.Command)
+	}
+	if m.Set == "" {
+		m.Set = "base"
+	}
+	if set, err := ParseMetricSets([]string{m.Set}); err != nil {
+		return fmt.Errorf("set: %w", err)
+	} else {
+		m.set = set
+	}
+	m.labelNames = nil
+	for name, field := range m.Labels {
+		if err := checkLabelName("labels", name); err != nil {
+			return err
+		}
+		if err := checkField(field); err != nil {
+			return fmt.Errorf("labels: %s: %w", name, err)
+		}
+		m.labelNames = append(m.labelNames, name)
+	}
+	slices.Sort(m.labelNames)
+
+	return nil
+}
+
+// Names of the metrics exported by the built-in collectors.
+var reservedMetricNames = make(map[string]bool)
+
+// ReserveMetricNames prevents custom metrics from using the names of the
+// metrics exported by the built-in collectors. It must be called before the
+// configuration is parsed.
+func ReserveMetricNames(names ...string) {
+	for _, name := range names {
+		reservedMetricNames[name] = true
+	}
+}
+
+func parseCustomCommands(commands []CustomCommand) error {
+	names := make(map[string]bool)
+
+	for i := range commands {
+		cmd := &commands[i]
+		if cmd.Daemon == "" || cmd.Command == "" {
+			return fmt.Errorf("custom-commands: %d: daemon and command are required", i)
+		}
+		prefix := fmt.Sprintf("custom-commands: %s %s", cmd.Daemon, cmd.Command)
+		fields, err := cmd.fields()
+		if err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		if len(cmd.Metrics) == 0 {
+			return fmt.Errorf("%s: no metrics", prefix)
+		}
+		for j := range cmd.Metrics {
+			m := &cmd.Metrics[j]
+			if err := m.check(cmd, fields); err != nil {
+				return fmt.Errorf("%s: metrics: %w", prefix, err)
+			}
+			if names[m.Name] {
+				return fmt.Errorf("%s: metrics: duplicate name: %q", prefix, m.Name)
+			}
+			if reservedMetricNames[m.Name] {
+				return fmt.Errorf("%s: metrics: name of a built-in metric: %q", prefix, m.Name)
+			}
+			names[m.Name] = true
+		}
+	}
+
+	return nil
+}
+
+// CustomCommands returns the unixctl commands run by the custom collector
+// and the metrics exported from their output.
+func CustomCommands() []CustomCommand { return current.Load().CustomCommands }
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Include and exclude regular expressions matched against an object name.
+// Both are anchored.
+type Filter struct {
+	Include string `yaml:"include"`
+	Exclude string `yaml:"exclude"`
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// Match returns true if name matches the include regex, or if it is not set,
+// and does not match the exclude regex.
+func (f *Filter) Match(name string) bool {
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+func (f *Filter) compile() error {
+	var err error
+	if f.include, err = compileAnchored(f.Include); err != nil {
+		return fmt.Errorf("include: %w", err)
+	}
+	if f.exclude, err = compileAnchored(f.Exclude); err != nil {
+		return fmt.Errorf("exclude: %w", err)
+	}
+	return nil
+}
+
+// The objects which can be filtered in a collector. Not all collectors
+// support all of them.
+type ObjectFilters struct {
+	Bridge        Filter `yaml:"bridge"`
+	Port          Filter `yaml:"port"`
+	Interface     Filter `yaml:"interface"`
+	InterfaceType Filter `yaml:"interface-type"`
+}
+
+func parseFilters(filters map[string]*ObjectFilters) error {
+	for collector, f := range filters {
+		for name, filter := range map[string]*Filter{
+			"bridge":         &f.Bridge,
+			"port":           &f.Port,
+			"interface":      &f.Interface,
+			"interface-type": &f.InterfaceType,
+		} {
+			if err := filter.compile(); err != nil {
+				return fmt.Errorf("filters: %s: %s: %w", collector, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+var noFilters ObjectFilters
+
+// Filters returns the object filters of a collector. When the collector has
+// no filters configured, all objects match.
+func Filters(collector string) *ObjectFilters {
+	if f, ok := current.Load().Filters[collector]; ok && f != nil {
+		return f
+	}
+	return &noFilters
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+)
+
+// An OVS/OVN instance monitored by the exporter. Empty settings default to
+// the top level values.
+type Instance struct {
+	// Value of the instance label added to all metrics of this instance.
+	// Empty for the implicit instance used when no instances are
+	// configured.
+	Name        string `yaml:"name"`
+	OvsRundir   string `yaml:"ovs-rundir"`
+	OvnRundir   string `yaml:"ovn-rundir"`
+	OvsdbRundir string `yaml:"ovsdb-rundir"`
+	OvsProcdir  string `yaml:"ovs-procdir"`
+	IntBrdNam   string `yaml:"br-int-name"`
+}
+
+func parseInstances(c *conf) error {
+	c.instances = nil
+	if len(c.Instances) == 0 {
+		c.instances = []*Instance{{
+			OvsRundir:   c.OvsRundir,
+			OvnRundir:   c.OvnRundir,
+			OvsdbRundir: c.OvsdbRundir,
+			OvsProcdir:  c.OvsProcdir,
+			IntBrdNam:   c.IntBrdNam,
+		}}
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for _, i := range c.Instances {
+		if i.Name == "" {
+			return fmt.Errorf("instances: missing name")
+		}
+		if names[i.Name] {
+			return fmt.Errorf("instances: duplicate name: %q", i.Name)
+		}
+		names[i.Name] = true
+
+		inst := i
+		for _, s := range []struct {
+			value *string
+			def   string
+		}{
+			{&inst.OvsRundir, c.OvsRundir},
+			{&inst.OvnRundir, c.OvnRundir},
+			{&inst.OvsdbRundir, c.OvsdbRundir},
+			{&inst.OvsProcdir, c.OvsProcdir},
+			{&inst.IntBrdNam, c.IntBrdNam},
+		} {
+			if *s.value == "" {
+				*s.value = s.def
+			}
+		}
+		c.instances = append(c.instances, &inst)
+	}
+
+	return nil
+}
+
+// Instances returns the monitored OVS/OVN instances. When none are
+// configured, a single instance with an empty name and the top level
+// settings is returned.
+func Instances() []*Instance { return current.Load().instances }
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+func checkLabelName(option, name string) error {
+	if !model.LabelName(name).IsValidLegacy() || strings.HasPrefix(name, "__") {
+		return fmt.Errorf("%s: invalid label name: %q", option, name)
+	}
+	return nil
+}
+
+func parseConstLabels(c *conf) error {
+	for name, value := range c.ConstLabels {
+		if err := checkLabelName("const-labels", name); err != nil {
+			return err
+		}
+		c.ConstLabels[name] = os.ExpandEnv(value)
+	}
+	for name, key := range c.ExternalIdLabels {
+		if err := checkLabelName("external-id-labels", name); err != nil {
+			return err
+		}
+		if _, ok := c.ConstLabels[name]; ok {
+			return fmt.Errorf("external-id-labels: %q is also in const-labels", name)
+		}
+		if key == "" {
+			return fmt.Errorf("external-id-labels: %s: empty external_ids key", name)
+		}
+	}
+	return nil
+}
+
+// ConstLabels returns the labels added to all metrics, after environment
+// variable expansion.
+func ConstLabels() map[string]string { return current.Load().ConstLabels }
+
+// ExternalIdLabels returns the labels added to all metrics whose value is
+// read from the external_ids column of the Open_vSwitch table, indexed by
+// label name.
+func ExternalIdLabels() map[string]string { return current.Load().ExternalIdLabels }
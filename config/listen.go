@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A single address or a list of addresses.
+type ListenAddrs []string
+
+func (l *ListenAddrs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var s string
+		if err := node.Decode(&s); err != nil {
+			return err
+		}
+		*l = ListenAddrs{s}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Kinds of listening sockets.
+const (
+	LISTEN_TCP     = "tcp"
+	LISTEN_UNIX    = "unix"
+	LISTEN_SYSTEMD = "systemd"
+)
+
+// A parsed http-listen entry.
+type ListenAddr struct {
+	// Serve HTTPS instead of plain HTTP.
+	Tls bool
+	// One of LISTEN_TCP, LISTEN_UNIX or LISTEN_SYSTEMD.
+	Network string
+	// TCP host:port, unix socket path or name of the systemd socket. An
+	// empty systemd name matches all sockets passed by systemd.
+	Address string
+}
+
+func (a ListenAddr) String() string {
+	scheme := "http"
+	if a.Tls {
+		scheme = "https"
+	}
+	switch a.Network {
+	case LISTEN_UNIX:
+		return fmt.Sprintf("%s://unix:%s", scheme, a.Address)
+	case LISTEN_SYSTEMD:
+		if a.Address == "" {
+			return fmt.Sprintf("%s://systemd", scheme)
+		}
+		return fmt.Sprintf("%s://systemd:%s", scheme, a.Address)
+	}
+	return fmt.Sprintf("%s://%s", scheme, a.Address)
+}
+
+// ParseListenAddr parses an http-listen entry with the following syntax:
+//
+//	[http://|https://](<host>:<port>|unix:<path>|systemd[:<name>])
+//
+// Without an explicit scheme, HTTPS is used if tlsDefault is true.
+func ParseListenAddr(s string, tlsDefault bool) (ListenAddr, error) {
+	a := ListenAddr{Tls: tlsDefault, Network: LISTEN_TCP}
+
+	if rest, ok := strings.CutPrefix(s, "http://"); ok {
+		a.Tls = false
+		s = rest
+	} else if rest, ok := strings.CutPrefix(s, "https://"); ok {
+		a.Tls = true
+		s = rest
+	}
+
+	if path, ok := strings.CutPrefix(s, "unix:"); ok {
+		if path == "" {
+			return a, fmt.Errorf("missing unix socket path")
+		}
+		a.Network = LISTEN_UNIX
+		a.Address = path
+	} else if s == "systemd" {
+		a.Network = LISTEN_SYSTEMD
+	} else if name, ok := strings.CutPrefix(s, "systemd:"); ok {
+		a.Network = LISTEN_SYSTEMD
+		a.Address = name
+	} else {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			return a, err
+		}
+		a.Address = s
+	}
+
+	return a, nil
+}
+
+func parseListen(c *conf) error {
+	tlsDefault := c.TlsCert != "" && c.TlsKey != ""
+	if len(c.HttpListen) == 0 {
+		return fmt.Errorf("http-listen: no address")
+	}
+	c.listen = nil
+	for _, s := range c.HttpListen {
+		a, err := ParseListenAddr(s, tlsDefault)
+		if err != nil {
+			return fmt.Errorf("http-listen: %q: %w", s, err)
+		}
+		if a.Tls && !tlsDefault {
+			return fmt.Errorf("http-listen: %q: requires tls-cert and tls-key", s)
+		}
+		c.listen = append(c.listen, a)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TLS settings used when connecting to a remote server.
+type ClientTLS struct {
+	// PEM bundle of certificate authorities used to verify the server
+	// certificate. The system pool is used if empty.
+	Ca string `yaml:"ca"`
+	// Client certificate and key presented to the server.
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	// Name used to verify the server certificate instead of the URL host.
+	ServerName         string `yaml:"server-name"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify"`
+}
+
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password-file"`
+}
+
+// Credentials sent to a remote server. The files are read before each
+// request so that they can be rotated without a restart.
+type Credentials struct {
+	BasicAuth       *BasicAuth `yaml:"basic-auth"`
+	BearerToken     string     `yaml:"bearer-token"`
+	BearerTokenFile string     `yaml:"bearer-token-file"`
+}
+
+func (c *ClientTLS) check() error {
+	if (c.Cert == "") != (c.Key == "") {
+		return errors.New("tls: cert and key must be set together")
+	}
+	return nil
+}
+
+func (c *Credentials) check() error {
+	bearer := c.BearerToken != "" || c.BearerTokenFile != ""
+	if c.BearerToken != "" && c.BearerTokenFile != "" {
+		return errors.New("bearer-token and bearer-token-file are mutually exclusive")
+	}
+	if c.BasicAuth != nil {
+		if bearer {
+			return errors.New("basic-auth and bearer-token are mutually exclusive")
+		}
+		if c.BasicAuth.Password != "" && c.BasicAuth.PasswordFile != "" {
+			return errors.New("basic-auth: password and password-file are mutually exclusive")
+		}
+	}
+	return nil
+}
+
+// Prometheus remote_write push settings.
+type RemoteWrite struct {
+	// Receiver URL. Push is disabled if empty.
+	Url string `yaml:"url"`
+	// How often metrics are gathered and queued.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout of each HTTP request.
+	Timeout time.Duration `yaml:"timeout"`
+	// Maximum number of pending requests. When full, the oldest is
+	// dropped.
+	QueueSize int `yaml:"queue-size"`
+	// Maximum number of retries of a failed request. Zero means retry
+	// until the queue is full.
+	MaxRetries int `yaml:"max-retries"`
+	// Delay between retries, doubled after each failure.
+	MinBackoff time.Duration `yaml:"min-backoff"`
+	MaxBackoff time.Duration `yaml:"max-backoff"`
+
+	Credentials `yaml:",inline"`
+	Tls         ClientTLS `yaml:"tls"`
+}
+
+func remoteWriteDefaults() RemoteWrite {
+	return RemoteWrite{
+		Interval:   30 * time.Second,
+		Timeout:    10 * time.Second,
+		QueueSize:  100,
+		MinBackoff: 1 * time.Second,
+		MaxBackoff: 1 * time.Minute,
+	}
+}
+
+func checkPushUrl(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme: %q", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("missing host in url: %q", u)
+	}
+	return nil
+}
+
+func (r *RemoteWrite) check() error {
+	if r.Url == "" {
+		return nil
+	}
+	if err := checkPushUrl(r.Url); err != nil {
+		return err
+	}
+	if r.Interval <= 0 || r.Timeout <= 0 {
+		return errors.New("interval and timeout must be positive")
+	}
+	if r.QueueSize <= 0 {
+		return errors.New("queue-size must be positive")
+	}
+	if r.MaxRetries < 0 {
+		return errors.New("max-retries must not be negative")
+	}
+	if r.MinBackoff <= 0 || r.MaxBackoff < r.MinBackoff {
+		return errors.New("min-backoff must be positive and lower than max-backoff")
+	}
+	if err := r.Credentials.check(); err != nil {
+		return err
+	}
+	return r.Tls.check()
+}
+
+// OTLP transport protocols.
+type OtlpProtocol string
+
+const (
+	OTLP_HTTP OtlpProtocol = "http/protobuf"
+	OTLP_GRPC OtlpProtocol = "grpc"
+)
+
+// OpenTelemetry OTLP metrics export settings.
+type Otlp struct {
+	// Collector URL. For http/protobuf, this is the full URL including the
+	// /v1/metrics path. For grpc, only the scheme, host and port are used.
+	// Export is disabled if empty.
+	Endpoint string       `yaml:"endpoint"`
+	Protocol OtlpProtocol `yaml:"protocol"`
+	// How often metrics are gathered and exported.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout of each export request.
+	Timeout time.Duration `yaml:"timeout"`
+	// Additional headers sent with each request.
+	Headers map[string]string `yaml:"headers"`
+	// Attributes of the resource which produces the metrics. Environment
+	// variables are expanded in the values.
+	ResourceAttributes map[string]string `yaml:"resource-attributes"`
+
+	Credentials `yaml:",inline"`
+	Tls         ClientTLS `yaml:"tls"`
+}
+
+func otlpDefaults() Otlp {
+	return Otlp{
+		Protocol: OTLP_HTTP,
+		Interval: 30 * time.Second,
+		Timeout:  10 * time.Second,
+	}
+}
+
+func (o *Otlp) check() error {
+	if o.Endpoint == "" {
+		return nil
+	}
+	if err := checkPushUrl(o.Endpoint); err != nil {
+		return err
+	}
+	switch o.Protocol {
+	case OTLP_HTTP, OTLP_GRPC:
+	default:
+		return fmt.Errorf("invalid protocol: %q", o.Protocol)
+	}
+	if o.Interval <= 0 || o.Timeout <= 0 {
+		return errors.New("interval and timeout must be positive")
+	}
+	for k, v := range o.ResourceAttributes {
+		o.ResourceAttributes[k] = os.ExpandEnv(v)
+	}
+	if err := o.Credentials.check(); err != nil {
+		return err
+	}
+	return o.Tls.check()
+}
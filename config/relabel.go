@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Relabel actions, with the same semantics as in prometheus
+// metric_relabel_configs.
+type RelabelAction string
+
+const (
+	// Replace target-label with replacement when regex matches the
+	// concatenated source-labels.
+	RELABEL_REPLACE RelabelAction = "replace"
+	// Drop the series when regex does not match the concatenated
+	// source-labels.
+	RELABEL_KEEP RelabelAction = "keep"
+	// Drop the series when regex matches the concatenated source-labels.
+	RELABEL_DROP RelabelAction = "drop"
+	// Copy the labels whose name match regex to the label name given by
+	// replacement.
+	RELABEL_LABELMAP RelabelAction = "labelmap"
+	// Remove the labels whose name match regex.
+	RELABEL_LABELDROP RelabelAction = "labeldrop"
+	// Remove the labels whose name do not match regex.
+	RELABEL_LABELKEEP RelabelAction = "labelkeep"
+)
+
+// A single relabel rule. The metric name is available as the __name__ label.
+type RelabelRule struct {
+	SourceLabels []string      `yaml:"source-labels"`
+	Separator    *string       `yaml:"separator"`
+	Regex        *string       `yaml:"regex"`
+	TargetLabel  string        `yaml:"target-label"`
+	Replacement  *string       `yaml:"replacement"`
+	Action       RelabelAction `yaml:"action"`
+	regex        *regexp.Regexp
+}
+
+// Regexp returns the compiled and anchored regex of the rule.
+func (r *RelabelRule) Regexp() *regexp.Regexp { return r.regex }
+
+// Sep returns the separator used to concatenate source-labels.
+func (r *RelabelRule) Sep() string { return *r.Separator }
+
+// Repl returns the replacement string of the rule.
+func (r *RelabelRule) Repl() string { return *r.Replacement }
+
+func ptr(s string) *string { return &s }
+
+// Fill default values, compile the regex and check the rule consistency.
+func parseRelabelRule(r *RelabelRule) error {
+	if r.Action == "" {
+		r.Action = RELABEL_REPLACE
+	}
+	if r.Separator == nil {
+		r.Separator = ptr(";")
+	}
+	if r.Regex == nil {
+		r.Regex = ptr("(.*)")
+	}
+	if r.Replacement == nil {
+		r.Replacement = ptr("$1")
+	}
+
+	re, err := regexp.Compile("^(?:" + *r.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %w", *r.Regex, err)
+	}
+	r.regex = re
+
+	for _, l := range r.SourceLabels {
+		if !model.LabelName(l).IsValidLegacy() {
+			return fmt.Errorf("invalid source label: %q", l)
+		}
+	}
+
+	switch r.Action {
+	case RELABEL_REPLACE:
+		if r.TargetLabel == "" {
+			return fmt.Errorf("action %q requires target-label", r.Action)
+		}
+		// target-label may reference capture groups, it is checked
+		// when the rule is applied
+		if !strings.Contains(r.TargetLabel, "$") &&
+			!model.LabelName(r.TargetLabel).IsValidLegacy() {
+			return fmt.Errorf("invalid target label: %q", r.TargetLabel)
+		}
+	case RELABEL_KEEP, RELABEL_DROP:
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("action %q requires source-labels", r.Action)
+		}
+	case RELABEL_LABELMAP:
+		if len(r.SourceLabels) > 0 || r.TargetLabel != "" {
+			return fmt.Errorf("action %q only uses regex and replacement", r.Action)
+		}
+	case RELABEL_LABELDROP, RELABEL_LABELKEEP:
+		if len(r.SourceLabels) > 0 || r.TargetLabel != "" ||
+			*r.Replacement != "$1" {
+			return fmt.Errorf("action %q only uses regex", r.Action)
+		}
+	default:
+		return fmt.Errorf("invalid relabel action: %q", r.Action)
+	}
+
+	return nil
+}
+
+// ParseRelabelRules fills the default values of rules, compiles their regex
+// and checks their consistency.
+func ParseRelabelRules(rules []RelabelRule) error {
+	for i := range rules {
+		if err := parseRelabelRule(&rules[i]); err != nil {
+			return fmt.Errorf("metric-relabel-configs[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
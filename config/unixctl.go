@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// Settings of the client running unixctl commands on the OVS/OVN daemons.
+type Unixctl struct {
+	// Maximum time spent connecting to the unixctl socket of a daemon.
+	ConnectTimeout time.Duration `yaml:"connect-timeout"`
+	// Maximum time spent waiting for the reply of one command. Commands
+	// are also aborted when the scrape-timeout expires.
+	CallTimeout time.Duration `yaml:"call-timeout"`
+	// Keep one connection open to each daemon instead of connecting for
+	// every command. The connection is re-established when the PID of the
+	// daemon changes.
+	ReuseConnections bool `yaml:"reuse-connections"`
+}
+
+func unixctlDefaults() Unixctl {
+	return Unixctl{
+		ConnectTimeout: 1 * time.Second,
+		CallTimeout:    2 * time.Second,
+	}
+}
+
+func (u *Unixctl) check() error {
+	if u.ConnectTimeout <= 0 || u.CallTimeout <= 0 {
+		return errors.New("connect-timeout and call-timeout must be positive")
+	}
+	return nil
+}
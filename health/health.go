@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package health checks whether the OVS/OVN backends used by the collectors
+// can be reached.
+package health
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+)
+
+// Name of the backend which groups all bridge OpenFlow management sockets.
+const openflowBackend = "openflow"
+
+// Name of the ovsdb-server backend used by ovs-vswitchd.
+const ovsdbBackend = "ovsdb"
+
+// Maximum time spent checking one backend, the same as for one unixctl
+// command.
+func checkTimeout() time.Duration {
+	return config.UnixctlSettings().CallTimeout
+}
+
+type Check struct {
+	Instance string `json:"instance,omitempty"`
+	Backend  string `json:"backend"`
+	Socket   string `json:"socket,omitempty"`
+	Ok       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks,omitempty"`
+}
+
+func newCheck(inst *instance.Instance, backend, socket string, err error) Check {
+	c := Check{Instance: inst.Name, Backend: backend, Socket: socket, Ok: err == nil}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+func checkOvsdb(inst *instance.Instance) Check {
+	socket, err := inst.Ovsdb.Ping()
+	return newCheck(inst, ovsdbBackend, socket, err)
+}
+
+func checkDaemon(inst *instance.Instance, daemon string) Check {
+	socket, err := inst.Appctl.Ping(daemon)
+	return newCheck(inst, daemon, socket, err)
+}
+
+func checkBridges(inst *instance.Instance) []Check {
+	var bridges []ovs.Bridge
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout())
+	defer cancel()
+
+	if err := ovsdb.List(ctx, inst.Ovsdb, &bridges); err != nil {
+		return []Check{newCheck(inst, openflowBackend, "", err)}
+	}
+
+	var checks []Check
+	for _, br := range bridges {
+		socket, err := inst.Openflow.Ping(br.Name)
+		checks = append(checks, newCheck(inst, openflowBackend, socket, err))
+	}
+	return checks
+}
+
+// Run all backend checks of all instances concurrently.
+func Run() []Check {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var checks []Check
+
+	add := func(c ...Check) {
+		lock.Lock()
+		checks = append(checks, c...)
+		lock.Unlock()
+	}
+
+	for _, inst := range instance.All() {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			add(checkOvsdb(inst))
+		}()
+		go func() {
+			defer wg.Done()
+			add(checkBridges(inst)...)
+		}()
+		for _, daemon := range appctl.Daemons() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				add(checkDaemon(inst, daemon))
+			}()
+		}
+	}
+	wg.Wait()
+
+	slices.SortFunc(checks, func(a, b Check) int {
+		return cmp.Or(
+			strings.Compare(a.Instance, b.Instance),
+			strings.Compare(a.Backend, b.Backend),
+			strings.Compare(a.Socket, b.Socket),
+		)
+	})
+
+	return checks
+}
+
+// Ready reports whether the checks satisfy the readiness criteria. All the
+// backends listed in readiness-backends must be reachable. If that list is
+// empty, at least one backend must be reachable.
+func Ready(checks []Check) bool {
+	required := config.ReadinessBackends()
+
+	if len(required) == 0 {
+		return slices.ContainsFunc(checks, func(c Check) bool { return c.Ok })
+	}
+	for _, backend := range required {
+		found := false
+		for _, c := range checks {
+			if c.Backend != backend {
+				continue
+			}
+			if !c.Ok {
+				return false
+			}
+			found = true
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func serve(w http.ResponseWriter, report Report, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Errf("health: %s", err)
+	}
+}
+
+// LivenessHandler always answers 200 as long as the exporter is able to
+// serve HTTP requests. The backends are not checked: restarting the exporter
+// does not fix an unreachable backend, and a slow backend must not delay the
+// probe.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, Report{Status: "ok"}, http.StatusOK)
+	})
+}
+
+// ReadinessHandler answers 200 when the readiness criteria are met and 503
+// otherwise. The body contains the state of each backend.
+func ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks := Run()
+		if Ready(checks) {
+			serve(w, Report{Status: "ok", Checks: checks}, http.StatusOK)
+		} else {
+			serve(w, Report{Status: "fail", Checks: checks}, http.StatusServiceUnavailable)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package instance holds the backends of each monitored OVS/OVN instance.
+package instance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/netlink"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/replay"
+)
+
+type Instance struct {
+	*config.Instance
+	Appctl   appctl.Backend
+	Ovsdb    ovsdb.Backend
+	Openflow openflow.Backend
+	Netlink  netlink.Backend
+}
+
+// New returns an instance with backends connected to the live sockets.
+func New(inst *config.Instance) *Instance {
+	return &Instance{
+		Instance: inst,
+		Appctl:   appctl.NewClient(inst),
+		Ovsdb:    ovsdb.NewClient(inst),
+		Openflow: openflow.NewClient(inst),
+		Netlink:  netlink.NewClient(),
+	}
+}
+
+// NewReplay returns an instance with backends reading the outputs recorded
+// in dir. Host wide data is read from root.
+func NewReplay(inst *config.Instance, dir, root string) *Instance {
+	return &Instance{
+		Instance: inst,
+		Appctl:   replay.NewAppctl(dir),
+		Ovsdb:    replay.NewOvsdb(dir),
+		Openflow: replay.NewOpenflow(dir, inst.IntBrdNam),
+		Netlink:  replay.NewNetlink(root),
+	}
+}
+
+var (
+	allOnce sync.Once
+	all     []*Instance
+)
+
+// All returns the instances of the configuration loaded on startup. The
+// instances settings require a restart to be changed.
+func All() []*Instance {
+	allOnce.Do(func() {
+		for _, inst := range config.Instances() {
+			all = append(all, New(inst))
+		}
+	})
+	return all
+}
+
+// Replay replaces the configured instances with the ones recorded in dir, as
+// in a support bundle. It must be called before All.
+func Replay(dir string) error {
+	replayed, err := replayInstances(dir)
+	if err != nil {
+		return err
+	}
+	ok := false
+	allOnce.Do(func() {
+		all = replayed
+		ok = true
+	})
+	if !ok {
+		return fmt.Errorf("replay: instances already initialized")
+	}
+	return nil
+}
+
+func replayInstance(name, dir, root string) *Instance {
+	return NewReplay(&config.Instance{
+		Name:        name,
+		OvsRundir:   filepath.Join(dir, "ovs-rundir"),
+		OvnRundir:   dir,
+		OvsdbRundir: dir,
+		OvsProcdir:  filepath.Join(dir, "proc"),
+		IntBrdNam:   config.IntBrdNam(),
+	}, dir, root)
+}
+
+func replayInstances(dir string) ([]*Instance, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "instances"))
+	if os.IsNotExist(err) {
+		return []*Instance{replayInstance("", dir, dir)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var res []*Instance
+	for _, e := range entries {
+		if e.IsDir() {
+			res = append(res, replayInstance(e.Name(), filepath.Join(dir, "instances", e.Name()), dir))
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("%s: no instances found", filepath.Join(dir, "instances"))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package instancetest provides OVS/OVN instances for testing collectors
+// against the fake backends of the appctltest, ovsdbtest and openflowtest
+// packages.
+package instancetest
+
+import (
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+)
+
+// New returns an instance connected to the live sockets of empty temporary
+// run directories. Fake backends are started by creating their sockets in
+// these directories.
+func New(t testing.TB) *instance.Instance {
+	t.Helper()
+
+	return instance.New(&config.Instance{
+		OvsRundir:   t.TempDir(),
+		OvnRundir:   t.TempDir(),
+		OvsdbRundir: t.TempDir(),
+		OvsProcdir:  t.TempDir(),
+		IntBrdNam:   "br-int",
+	})
+}
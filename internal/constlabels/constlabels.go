@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package constlabels adds the const-labels and external-id-labels of the
+// active configuration to all gathered metrics. The external-id-labels values
+// are read from the ovsdb of the instance the metrics come from.
+package constlabels
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// How long the Open_vSwitch external_ids are cached.
+const refreshInterval = 30 * time.Second
+
+// The cached Open_vSwitch external_ids of one instance.
+type externalIds struct {
+	lock    sync.Mutex
+	ids     map[string]string
+	fetched time.Time
+}
+
+var (
+	cachesLock sync.Mutex
+	caches     = make(map[*instance.Instance]*externalIds)
+)
+
+// Return the external_ids column of the Open_vSwitch table of inst. When
+// ovsdb cannot be reached, the last known values are returned.
+func ovsExternalIds(inst *instance.Instance) map[string]string {
+	cachesLock.Lock()
+	cache, ok := caches[inst]
+	if !ok {
+		cache = new(externalIds)
+		caches[inst] = cache
+	}
+	cachesLock.Unlock()
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if time.Since(cache.fetched) < refreshInterval {
+		return cache.ids
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	var vswitch ovs.OpenvSwitch
+	if err := ovsdb.Get(ctx, inst.Ovsdb, &vswitch); err != nil {
+		return cache.ids
+	}
+	cache.ids = vswitch.ExternalIDs
+	cache.fetched = time.Now()
+
+	return cache.ids
+}
+
+// Labels returns the labels to add to the metrics of inst. Labels from
+// external-id-labels are omitted until their value is known.
+func Labels(inst *instance.Instance) map[string]string {
+	labels := maps.Clone(config.ConstLabels())
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if idLabels := config.ExternalIdLabels(); len(idLabels) > 0 {
+		ids := ovsExternalIds(inst)
+		for name, key := range idLabels {
+			if value := ids[key]; value != "" {
+				labels[name] = value
+			}
+		}
+	}
+	return labels
+}
+
+type gatherer struct {
+	prometheus.Gatherer
+	inst *instance.Instance
+}
+
+// Gatherer wraps g so that the constant labels of the active configuration
+// and the external-id-labels of inst are added to everything it returns.
+func Gatherer(g prometheus.Gatherer, inst *instance.Instance) prometheus.Gatherer {
+	return gatherer{g, inst}
+}
+
+func (g gatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	labels := Labels(g.inst)
+	if len(labels) > 0 {
+		Add(families, labels)
+	}
+	return families, err
+}
+
+// Add labels to all metrics of families. Labels already set on a metric
+// are left untouched.
+func Add(families []*dto.MetricFamily, labels map[string]string) {
+	for _, family := range families {
+		for _, m := range family.Metric {
+			for _, name := range slices.Sorted(maps.Keys(labels)) {
+				if slices.ContainsFunc(m.Label, func(l *dto.LabelPair) bool {
+					return l.GetName() == name
+				}) {
+					continue
+				}
+				m.Label = append(m.Label, &dto.LabelPair{
+					Name: ptr(name), Value: ptr(labels[name]),
+				})
+			}
+			slices.SortFunc(m.Label, func(a, b *dto.LabelPair) int {
+				return cmp.Compare(a.GetName(), b.GetName())
+			})
+		}
+	}
+}
+
+func ptr(s string) *string { return &s }
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package constlabels
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance/instancetest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovsdbtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGatherer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+const-labels:
+  region: ${TEST_REGION}
+  bridge: ignored
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_REGION", "east")
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ovs_test", Help: "test gauge",
+	}, []string{"bridge"})
+	gauge.WithLabelValues("br-int").Set(1)
+	registry.MustRegister(gauge)
+
+	err = testutil.GatherAndCompare(Gatherer(registry, instancetest.New(t)), strings.NewReader(`
+# HELP ovs_test test gauge
+# TYPE ovs_test gauge
+ovs_test{bridge="br-int",region="east"} 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExternalIdsPerInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("external-id-labels: {host: hostname}\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"compute-0", "compute-1"} {
+		inst := instancetest.New(t)
+		db := ovsdbtest.NewServer(t, inst.OvsRundir)
+		db.Insert(t, &ovs.OpenvSwitch{
+			ExternalIDs: map[string]string{"hostname": host},
+		})
+
+		registry := prometheus.NewRegistry()
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ovs_test", Help: "test gauge",
+		})
+		gauge.Set(1)
+		registry.MustRegister(gauge)
+
+		err = testutil.GatherAndCompare(Gatherer(registry, inst), strings.NewReader(`
+# HELP ovs_test test gauge
+# TYPE ovs_test gauge
+ovs_test{host="`+host+`"} 1
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInvalidLabel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("const-labels: {__name__: foo}\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	if err := config.Parse(); err == nil {
+		t.Fatal("expected error")
+	}
+}
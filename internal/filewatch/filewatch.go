@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package filewatch detects modifications of files by polling their status.
+// Polling is used instead of inotify so that the atomic symlink swaps done by
+// kubernetes on mounted ConfigMaps and Secrets are detected as well.
+package filewatch
+
+import (
+	"os"
+	"time"
+)
+
+// Changed reports whether a file was created, removed or modified between two
+// calls to os.Stat. A nil FileInfo means that the file did not exist.
+func Changed(old, new os.FileInfo) bool {
+	switch {
+	case old == nil && new == nil:
+		return false
+	case old == nil || new == nil:
+		return true
+	}
+	return !os.SameFile(old, new) ||
+		!old.ModTime().Equal(new.ModTime()) ||
+		old.Size() != new.Size()
+}
+
+// Poll checks the files returned by paths every interval and calls fn with
+// the list of modified ones. The list of files may change between calls. Poll
+// never returns.
+func Poll(interval time.Duration, paths func() []string, fn func(changed []string)) {
+	last := stat(paths())
+
+	for range time.Tick(interval) {
+		var changed []string
+
+		current := stat(paths())
+		for path, info := range current {
+			if old, ok := last[path]; ok && Changed(old, info) {
+				changed = append(changed, path)
+			}
+		}
+		if len(changed) > 0 {
+			fn(changed)
+		}
+		// fn may have changed the list of files, only stat the new ones
+		last = make(map[string]os.FileInfo, len(current))
+		for _, path := range paths() {
+			info, ok := current[path]
+			if !ok {
+				info, _ = os.Stat(path)
+			}
+			last[path] = info
+		}
+	}
+}
+
+func stat(paths []string) map[string]os.FileInfo {
+	infos := make(map[string]os.FileInfo, len(paths))
+	for _, path := range paths {
+		infos[path], _ = os.Stat(path)
+	}
+	return infos
+}
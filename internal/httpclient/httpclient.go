@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package httpclient builds the HTTP clients used to push metrics to remote
+// servers.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+)
+
+// TLSConfig returns the TLS configuration used to connect to a server.
+func TLSConfig(c *config.ClientTLS) (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.Ca != "" {
+		pem, err := os.ReadFile(c.Ca)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no valid PEM certificate found", c.Ca)
+		}
+	}
+	if c.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// New returns an HTTP client with the given TLS settings and request timeout.
+func New(c *config.ClientTLS, timeout time.Duration) (*http.Client, error) {
+	tlsConf, err := TLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConf
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+func readSecret(path string) (string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// Authorization returns the value of the Authorization header for the given
+// credentials, or an empty string if there are none. Secret files are read on
+// each call.
+func Authorization(c *config.Credentials) (string, error) {
+	token := c.BearerToken
+	if c.BearerTokenFile != "" {
+		var err error
+		if token, err = readSecret(c.BearerTokenFile); err != nil {
+			return "", err
+		}
+	}
+	if token != "" {
+		return "Bearer " + token, nil
+	}
+
+	if c.BasicAuth != nil {
+		password := c.BasicAuth.Password
+		if c.BasicAuth.PasswordFile != "" {
+			var err error
+			if password, err = readSecret(c.BasicAuth.PasswordFile); err != nil {
+				return "", err
+			}
+		}
+		req := http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(c.BasicAuth.Username, password)
+		return req.Header.Get("Authorization"), nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package relabel applies the metric-relabel-configs rules to the gathered
+// metrics before they are served.
+package relabel
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+type gatherer struct {
+	prometheus.Gatherer
+}
+
+// Gatherer wraps g so that the relabel rules of the active configuration are
+// applied to everything it returns.
+func Gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return gatherer{g}
+}
+
+func (g gatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	rules := config.RelabelRules()
+	if len(rules) == 0 {
+		return families, err
+	}
+	return Families(families, rules), err
+}
+
+// Families applies rules to all metrics and returns the resulting families.
+// Metrics may be dropped, or moved to another family if their name is
+// changed.
+func Families(families []*dto.MetricFamily, rules []config.RelabelRule) []*dto.MetricFamily {
+	result := make(map[string]*dto.MetricFamily)
+	seen := make(map[string]bool)
+
+	for _, family := range families {
+		for _, m := range family.Metric {
+			labels := make(map[string]string, len(m.Label)+1)
+			for _, l := range m.Label {
+				labels[l.GetName()] = l.GetValue()
+			}
+			labels[model.MetricNameLabel] = family.GetName()
+
+			if !Process(labels, rules) {
+				continue
+			}
+			name := labels[model.MetricNameLabel]
+			if name == "" {
+				continue
+			}
+			delete(labels, model.MetricNameLabel)
+
+			f, ok := result[name]
+			if !ok {
+				f = &dto.MetricFamily{
+					Name: &name,
+					Help: family.Help,
+					Type: family.Type,
+				}
+				result[name] = f
+			} else if f.GetType() != family.GetType() {
+				log.Warningf("relabel: %s: dropping %s series with %s type",
+					name, family.GetType(), f.GetType())
+				continue
+			}
+
+			key := seriesKey(name, labels)
+			if seen[key] {
+				log.Warningf("relabel: dropping duplicate series %s", key)
+				continue
+			}
+			seen[key] = true
+
+			m.Label = m.Label[:0]
+			for _, n := range slices.Sorted(maps.Keys(labels)) {
+				m.Label = append(m.Label, &dto.LabelPair{
+					Name: &n, Value: ptr(labels[n]),
+				})
+			}
+			f.Metric = append(f.Metric, m)
+		}
+	}
+
+	var out []*dto.MetricFamily
+	for _, name := range slices.Sorted(maps.Keys(result)) {
+		out = append(out, result[name])
+	}
+	return out
+}
+
+func ptr(s string) *string { return &s }
+
+func seriesKey(name string, labels map[string]string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteString("{")
+	for i, n := range slices.Sorted(maps.Keys(labels)) {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(n)
+		b.WriteString("=")
+		b.WriteString(strconv.Quote(labels[n]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Process applies rules in order to labels which are modified in place. The
+// metric name is stored in the __name__ label. Returns false if the series
+// must be dropped. Labels with an empty value are removed.
+func Process(labels map[string]string, rules []config.RelabelRule) bool {
+	for i := range rules {
+		if !apply(labels, &rules[i]) {
+			return false
+		}
+	}
+	for n, v := range labels {
+		if v == "" {
+			delete(labels, n)
+		}
+	}
+	return true
+}
+
+func apply(labels map[string]string, rule *config.RelabelRule) bool {
+	re := rule.Regexp()
+
+	values := make([]string, 0, len(rule.SourceLabels))
+	for _, n := range rule.SourceLabels {
+		values = append(values, labels[n])
+	}
+	val := strings.Join(values, rule.Sep())
+
+	switch rule.Action {
+	case config.RELABEL_KEEP:
+		return re.MatchString(val)
+	case config.RELABEL_DROP:
+		return !re.MatchString(val)
+	case config.RELABEL_REPLACE:
+		idx := re.FindStringSubmatchIndex(val)
+		if idx == nil {
+			break
+		}
+		target := string(re.ExpandString(nil, rule.TargetLabel, val, idx))
+		if !model.LabelName(target).IsValidLegacy() {
+			break
+		}
+		res := re.ExpandString(nil, rule.Repl(), val, idx)
+		if len(res) == 0 {
+			delete(labels, target)
+		} else {
+			labels[target] = string(res)
+		}
+	case config.RELABEL_LABELMAP:
+		for n, v := range maps.Clone(labels) {
+			if !re.MatchString(n) {
+				continue
+			}
+			target := re.ReplaceAllString(n, rule.Repl())
+			if model.LabelName(target).IsValidLegacy() {
+				labels[target] = v
+			}
+		}
+	case config.RELABEL_LABELDROP:
+		for n := range labels {
+			if re.MatchString(n) {
+				delete(labels, n)
+			}
+		}
+	case config.RELABEL_LABELKEEP:
+		for n := range labels {
+			if n != model.MetricNameLabel && !re.MatchString(n) {
+				delete(labels, n)
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package relabel
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"gopkg.in/yaml.v3"
+)
+
+func parseRules(t *testing.T, s string) []config.RelabelRule {
+	t.Helper()
+	var rules []config.RelabelRule
+	if err := yaml.Unmarshal([]byte(s), &rules); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.ParseRelabelRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  string
+		labels map[string]string
+		want   map[string]string
+	}{
+		{
+			name: "drop by name",
+			rules: `
+- source-labels: [__name__]
+  regex: ovs_coverage_.*
+  action: drop
+`,
+			labels: map[string]string{"__name__": "ovs_coverage_total", "event": "x"},
+			want:   nil,
+		},
+		{
+			name: "drop by label value",
+			rules: `
+- source-labels: [bridge, interface]
+  regex: br-int;tap.*
+  action: drop
+`,
+			labels: map[string]string{"__name__": "ovs_interface_rx_bytes", "bridge": "br-ex", "interface": "tap0"},
+			want:   map[string]string{"__name__": "ovs_interface_rx_bytes", "bridge": "br-ex", "interface": "tap0"},
+		},
+		{
+			name: "rename label",
+			rules: `
+- source-labels: [interface]
+  target-label: device
+- action: labeldrop
+  regex: interface
+`,
+			labels: map[string]string{"__name__": "m", "interface": "tap0"},
+			want:   map[string]string{"__name__": "m", "device": "tap0"},
+		},
+		{
+			name: "replace label value",
+			rules: `
+- source-labels: [interface]
+  regex: (tap|vhu)[0-9a-f-]+
+  target-label: interface
+  replacement: ${1}-port
+`,
+			labels: map[string]string{"__name__": "m", "interface": "tap1234"},
+			want:   map[string]string{"__name__": "m", "interface": "tap-port"},
+		},
+		{
+			name: "static label",
+			rules: `
+- target-label: region
+  replacement: east
+`,
+			labels: map[string]string{"__name__": "m"},
+			want:   map[string]string{"__name__": "m", "region": "east"},
+		},
+		{
+			name: "labelkeep",
+			rules: `
+- action: labelkeep
+  regex: bridge
+`,
+			labels: map[string]string{"__name__": "m", "bridge": "br-int", "port": "p"},
+			want:   map[string]string{"__name__": "m", "bridge": "br-int"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRules(t, tt.rules)
+			labels := maps.Clone(tt.labels)
+			keep := Process(labels, rules)
+			if tt.want == nil {
+				if keep {
+					t.Fatalf("series not dropped: %v", labels)
+				}
+				return
+			}
+			if !keep {
+				t.Fatal("series dropped")
+			}
+			if !maps.Equal(labels, tt.want) {
+				t.Fatalf("got %v, want %v", labels, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{
+		`[{action: foo}]`,
+		`[{action: drop}]`,
+		`[{action: replace}]`,
+		`[{regex: "(", target-label: x}]`,
+		`[{action: labeldrop, target-label: x}]`,
+	} {
+		var rules []config.RelabelRule
+		if err := yaml.Unmarshal([]byte(s), &rules); err != nil {
+			t.Fatal(err)
+		}
+		if err := config.ParseRelabelRules(rules); err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package selfmetrics holds the metrics that the exporter reports about
+// itself, as opposed to the OVS/OVN metrics exported by the collectors.
+package selfmetrics
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "openstack_network_exporter"
+
+// Backend identifies a data source used by the collectors.
+type Backend string
+
+const (
+	Unixctl  Backend = "unixctl"
+	Ovsdb    Backend = "ovsdb"
+	Openflow Backend = "openflow"
+	Netlink  Backend = "netlink"
+)
+
+var backends = []Backend{Unixctl, Ovsdb, Openflow, Netlink}
+
+// Name of the metric reporting whether the last scrape of a collector
+// succeeded.
+var CollectorSuccessName = prometheus.BuildFQName(namespace, "collector", "success")
+
+var (
+	CollectorSuccess = prometheus.NewDesc(
+		CollectorSuccessName,
+		"Whether the last scrape of the collector succeeded (1) or failed (0).",
+		[]string{"collector"}, nil)
+	CollectorDuration = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "collector", "duration_seconds"),
+		"Time spent during the last scrape of the collector.",
+		[]string{"collector"}, nil)
+	CollectorSnapshotAge = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "collector", "snapshot_age_seconds"),
+		"Time elapsed since the exported metrics of the collector were retrieved.",
+		[]string{"collector"}, nil)
+	CollectorErrors = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "collector", "errors_total"),
+		"Number of failed scrapes of the collector, by reason (error, timeout).",
+		[]string{"collector", "reason"}, nil)
+
+	backendErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "backend_errors_total",
+			Help:      "Number of failed requests to a backend (unixctl, ovsdb, openflow, netlink).",
+		},
+		[]string{"backend"})
+)
+
+var (
+	remoteWriteBatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "remote_write_batches_total",
+			Help:      "Number of remote_write batches sent or dropped.",
+		},
+		[]string{"result"})
+	otlpExports = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "otlp_exports_total",
+			Help:      "Number of successful and failed OTLP metrics exports.",
+		},
+		[]string{"result"})
+)
+
+var (
+	tlsCertExpiry = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "tls_cert", "expiry_timestamp_seconds"),
+		"Expiration time of the TLS certificate being served, in seconds since the epoch.",
+		nil, nil)
+	tlsCertNotAfter atomic.Int64
+)
+
+func init() {
+	// report all backends, even if they never failed
+	for _, b := range backends {
+		backendErrors.WithLabelValues(string(b))
+	}
+}
+
+// Increment the error counter of a backend.
+func BackendError(b Backend) {
+	backendErrors.WithLabelValues(string(b)).Inc()
+}
+
+// Count a remote_write batch accepted by the receiver.
+func RemoteWriteSent() {
+	remoteWriteBatches.WithLabelValues("sent").Inc()
+}
+
+// Count a remote_write batch dropped because of an unrecoverable error, too
+// many retries or a full queue.
+func RemoteWriteDropped() {
+	remoteWriteBatches.WithLabelValues("dropped").Inc()
+}
+
+// Count an OTLP export accepted by the collector.
+func OtlpExported() {
+	otlpExports.WithLabelValues("success").Inc()
+}
+
+// Count an OTLP export which failed after all retries.
+func OtlpFailed() {
+	otlpExports.WithLabelValues("failure").Inc()
+}
+
+// Record the expiration time of the TLS certificate being served.
+func SetTLSCertExpiry(notAfter time.Time) {
+	tlsCertNotAfter.Store(notAfter.Unix())
+}
+
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- CollectorSuccess
+	ch <- CollectorDuration
+	ch <- CollectorSnapshotAge
+	ch <- CollectorErrors
+	ch <- tlsCertExpiry
+	backendErrors.Describe(ch)
+	remoteWriteBatches.Describe(ch)
+	otlpExports.Describe(ch)
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	backendErrors.Collect(ch)
+	remoteWriteBatches.Collect(ch)
+	otlpExports.Collect(ch)
+	if notAfter := tlsCertNotAfter.Load(); notAfter != 0 {
+		ch <- prometheus.MustNewConstMetric(tlsCertExpiry,
+			prometheus.GaugeValue, float64(notAfter))
+	}
+}
+
+// Collector returns a prometheus collector which exports the backend error
+// counters and the TLS certificate expiration time. It also declares the
+// per-collector descriptors which are emitted by the shared collectors
+// themselves. It must be registered in every registry where shared
+// collectors are registered.
+func Collector() prometheus.Collector {
+	return collector{}
+}
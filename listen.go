@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+)
+
+// First file descriptor passed by systemd socket activation.
+const listenFdsStart = 3
+
+// A listening socket passed by systemd.
+type activatedSocket struct {
+	name     string
+	listener net.Listener
+}
+
+// Return the listening sockets passed by systemd via the LISTEN_PID,
+// LISTEN_FDS and LISTEN_FDNAMES environment variables. The variables are
+// unset so that they are not inherited by child processes.
+func activatedSockets() ([]activatedSocket, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	var sockets []activatedSocket
+	for i := range nfds {
+		fd := listenFdsStart + i
+		syscall.CloseOnExec(fd)
+
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket %s: %w", name, err)
+		}
+		sockets = append(sockets, activatedSocket{name: name, listener: l})
+	}
+
+	return sockets, nil
+}
+
+// Listen on a unix socket, removing any stale socket file left by a previous
+// instance.
+func listenUnix(path string) (net.Listener, error) {
+	if st, err := os.Lstat(path); err == nil && st.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// A listening socket and the http-listen entry it was created for.
+type listener struct {
+	net.Listener
+	addr config.ListenAddr
+}
+
+// Open all listening sockets. When the exporter was socket activated and
+// none of the http-listen entries refer to systemd sockets, the activated
+// sockets replace the http-listen entries.
+func openListeners(addrs []config.ListenAddr) ([]listener, error) {
+	sockets, err := activatedSockets()
+	if err != nil {
+		return nil, err
+	}
+
+	useSystemd := false
+	for _, a := range addrs {
+		if a.Network == config.LISTEN_SYSTEMD {
+			useSystemd = true
+		}
+	}
+	if len(sockets) > 0 && !useSystemd {
+		log.Noticef("socket activated, ignoring http-listen addresses")
+		tls := config.TlsCert() != "" && config.TlsKey() != ""
+		addrs = []config.ListenAddr{{Tls: tls, Network: config.LISTEN_SYSTEMD}}
+	}
+
+	var listeners []listener
+	used := make(map[int]bool)
+
+	for _, a := range addrs {
+		switch a.Network {
+		case config.LISTEN_SYSTEMD:
+			found := false
+			for i, s := range sockets {
+				if a.Address != "" && a.Address != s.name {
+					continue
+				}
+				if used[i] {
+					return nil, fmt.Errorf("%s: socket %s already used", a, s.name)
+				}
+				used[i] = true
+				found = true
+				sa := a
+				sa.Address = s.name
+				listeners = append(listeners, listener{s.listener, sa})
+			}
+			if !found {
+				return nil, fmt.Errorf("%s: no matching socket passed by systemd", a)
+			}
+		case config.LISTEN_UNIX:
+			l, err := listenUnix(a.Address)
+			if err != nil {
+				return nil, err
+			}
+			listeners = append(listeners, listener{l, a})
+		default:
+			l, err := net.Listen("tcp", a.Address)
+			if err != nil {
+				return nil, err
+			}
+			listeners = append(listeners, listener{l, a})
+		}
+	}
+
+	for i, s := range sockets {
+		if !used[i] {
+			log.Warningf("systemd socket %s not used by http-listen", s.name)
+			s.listener.Close()
+		}
+	}
+	if len(listeners) == 0 {
+		return nil, errors.New("no listening socket")
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2024 Robin Jarry
+
+package log
+
+import (
+	"fmt"
+	"log"
+	"log/syslog"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// io.Writer used by the standard library loggers.
+type logWriter struct {
+	logger *Logger
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	if w.logger.enabled(syslog.LOG_ERR) {
+		// skip log.(*Logger).output and log.(*Logger).Printf
+		w.logger.output(4, syslog.LOG_ERR, string(p))
+	}
+	return len(p), nil
+}
+
+// Logger for the errors of the HTTP servers. Messages are logged with the
+// "http" module.
+func ErrorLogger() *log.Logger {
+	return log.New(&logWriter{logger: Module("http")}, "", 0)
+}
+
+type sink struct {
+	logger    *Logger
+	calldepth int
+}
+
+func (s *sink) Enabled(level int) bool {
+	return s.logger.enabled(logrPriority(level))
+}
+
+// Conversion of logr verbosity levels to syslog priorities.
+func logrPriority(level int) syslog.Priority {
+	switch {
+	case level <= 0:
+		return syslog.LOG_NOTICE
+	case level == 1:
+		return syslog.LOG_INFO
+	default:
+		return syslog.LOG_DEBUG
+	}
+}
+
+func (s *sink) Error(e error, msg string, args ...any) {
+	if s.logger.enabled(syslog.LOG_ERR) {
+		s.logger.With(args...).With("error", e).output(s.calldepth, syslog.LOG_ERR, msg)
+	}
+}
+
+func (s *sink) Info(level int, msg string, args ...any) {
+	prio := logrPriority(level)
+	if s.logger.enabled(prio) {
+		s.logger.With(args...).output(s.calldepth, prio, msg)
+	}
+}
+
+func (s *sink) Init(info logr.RuntimeInfo) {
+	s.calldepth = info.CallDepth + 2
+}
+
+func (s *sink) WithValues(keysAndValues ...any) logr.LogSink {
+	return &sink{logger: s.logger.With(keysAndValues...), calldepth: s.calldepth}
+}
+
+func (s *sink) WithName(name string) logr.LogSink {
+	return &sink{logger: s.logger.With("logger", name), calldepth: s.calldepth}
+}
+
+// Logger for the libovsdb client. Messages are logged with the "ovsdb"
+// module and the given key/value pairs.
+func OvsdbLogger(keysAndValues ...any) *logr.Logger {
+	l := logr.New(&sink{logger: Module("ovsdb").With(keysAndValues...)})
+	return &l
+}
+
+// used by prometheus error logging
+func (s *sink) Println(args ...any) {
+	if s.logger.enabled(syslog.LOG_ERR) {
+		s.logger.output(2, syslog.LOG_ERR, fmt.Sprintln(args...))
+	}
+}
+
+// Logger for the errors of the prometheus HTTP handler. Messages are logged
+// with the "prometheus" module.
+func PrometheusLogger() promhttp.Logger {
+	return &sink{logger: Module("prometheus")}
+}
@@ -4,25 +4,38 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"log/syslog"
 	"os"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+)
 
-	"github.com/go-logr/logr"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+// Supported log formats.
+const (
+	FORMAT_TEXT   = "text"
+	FORMAT_JSON   = "json"
+	FORMAT_LOGFMT = "logfmt"
 )
 
 var (
-	debug     *log.Logger
-	info      *log.Logger
-	notice    *log.Logger
-	warning   *log.Logger
-	err       *log.Logger
-	crit      *log.Logger
-	verbosity syslog.Priority
-	writer    *syslog.Writer
+	debug   *log.Logger
+	info    *log.Logger
+	notice  *log.Logger
+	warning *log.Logger
+	err     *log.Logger
+	crit    *log.Logger
+	levels  atomic.Pointer[Levels]
+	writer  *syslog.Writer
+	// handler for the json and logfmt formats
+	handler slog.Handler
 )
 
 // Parse a log level from a string and return an integer value
@@ -47,199 +60,344 @@ func ParseLogLevel(s string) (syslog.Priority, error) {
 	return prio, nil
 }
 
-// Initialize the logging system.
-// Redirect messages to syslog if run by systemd.
-func InitLogging(level syslog.Priority) error {
-	verbosity = level
-	if os.Getenv("INVOCATION_ID") != "" {
-		// executed by systemd
-		w, err := syslog.New(syslog.LOG_DAEMON, "")
+// Log verbosity with optional per-module overrides.
+type Levels struct {
+	Default syslog.Priority
+	Modules map[string]syslog.Priority
+}
+
+// Parse a comma separated list of log levels. Each element is either a level
+// which applies to all modules or a <module>=<level> override. For example:
+// "notice,ovsdb=debug". The default level is notice.
+func ParseLevels(s string) (*Levels, error) {
+	l := &Levels{Default: syslog.LOG_NOTICE, Modules: make(map[string]syslog.Priority)}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		module, level, found := strings.Cut(item, "=")
+		if !found {
+			level = module
+		}
+		prio, err := ParseLogLevel(strings.TrimSpace(level))
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if found {
+			module = strings.TrimSpace(module)
+			if module == "" {
+				return nil, fmt.Errorf("missing module name in %q", item)
+			}
+			l.Modules[module] = prio
+		} else {
+			l.Default = prio
 		}
-		writer = w
-	} else {
-		flags := log.Ltime | log.Lshortfile
-		debug = log.New(os.Stderr, "DEBUG   ", flags)
-		info = log.New(os.Stderr, "INFO    ", flags)
-		notice = log.New(os.Stderr, "NOTICE  ", flags)
-		warning = log.New(os.Stderr, "WARNING ", flags)
-		err = log.New(os.Stderr, "ERROR   ", flags)
-		crit = log.New(os.Stderr, "CRIT    ", flags)
 	}
-	return nil
+	return l, nil
 }
 
-func format(message string, args ...any) string {
-	return fmt.Sprintf(strings.TrimSpace(message)+"\n", args...)
+// Return the log level of a module.
+func (l *Levels) Level(module string) syslog.Priority {
+	if prio, ok := l.Modules[module]; ok {
+		return prio
+	}
+	return l.Default
 }
 
-// Write a DEBUG message to the log
-func Debugf(message string, args ...any) {
-	if verbosity < syslog.LOG_DEBUG {
-		return
-	}
-	msg := format(message, args...)
-	if writer != nil {
-		_ = writer.Debug(msg)
-	} else if debug != nil {
-		_ = debug.Output(2, msg)
+// Check that a log format is supported.
+func CheckFormat(format string) error {
+	switch format {
+	case FORMAT_TEXT, FORMAT_JSON, FORMAT_LOGFMT:
+		return nil
 	}
+	return fmt.Errorf("invalid log format %q", format)
 }
 
-// Write an INFO message to the log
-func Infof(message string, args ...any) {
-	if verbosity < syslog.LOG_INFO {
-		return
-	}
-	msg := format(message, args...)
-	if writer != nil {
-		_ = writer.Info(msg)
-	} else if info != nil {
-		_ = info.Output(2, msg)
+// Initialize the logging system.
+// In text format, redirect messages to syslog if run by systemd. The json and
+// logfmt formats are always written to stderr.
+func InitLogging(l *Levels, format string) error {
+	SetLevels(l)
+
+	switch format {
+	case FORMAT_JSON:
+		handler = slog.NewJSONHandler(os.Stderr, handlerOptions)
+	case FORMAT_LOGFMT:
+		handler = slog.NewTextHandler(os.Stderr, handlerOptions)
+	case FORMAT_TEXT:
+		if os.Getenv("INVOCATION_ID") != "" {
+			// executed by systemd
+			w, err := syslog.New(syslog.LOG_DAEMON, "")
+			if err != nil {
+				return err
+			}
+			writer = w
+		} else {
+			flags := log.Ltime | log.Lshortfile
+			debug = log.New(os.Stderr, "DEBUG   ", flags)
+			info = log.New(os.Stderr, "INFO    ", flags)
+			notice = log.New(os.Stderr, "NOTICE  ", flags)
+			warning = log.New(os.Stderr, "WARNING ", flags)
+			err = log.New(os.Stderr, "ERROR   ", flags)
+			crit = log.New(os.Stderr, "CRIT    ", flags)
+		}
+	default:
+		return CheckFormat(format)
 	}
+	return nil
 }
 
-// Write a NOTICE message to the log
-func Noticef(message string, args ...any) {
-	if verbosity < syslog.LOG_NOTICE {
-		return
+// Change the log verbosity. This is safe to call at any time.
+func SetLevels(l *Levels) {
+	levels.Store(l)
+}
+
+func enabled(module string, level syslog.Priority) bool {
+	l := levels.Load()
+	if l == nil {
+		return false
 	}
-	msg := format(message, args...)
-	if writer != nil {
-		_ = writer.Notice(msg)
-	} else if notice != nil {
-		_ = notice.Output(2, msg)
+	return l.Level(module) >= level
+}
+
+func format(message string, args ...any) string {
+	return fmt.Sprintf(strings.TrimSpace(message), args...)
+}
+
+// Logger writes messages on behalf of a module with additional key/value
+// fields. Well known keys are "collector", "daemon", "socket" and "error".
+type Logger struct {
+	module string
+	attrs  []any
+}
+
+// Messages logged with the package functions.
+var root = new(Logger)
+
+// Return a logger for a module. The module name can be used to override the
+// log level, see ParseLevels.
+func Module(name string) *Logger {
+	return &Logger{module: name}
+}
+
+// Return a logger for a collector. The collector name is used as module.
+func Collector(name string) *Logger {
+	return Module(name).With("collector", name)
+}
+
+// Return a logger which adds key/value pairs to all messages.
+func With(args ...any) *Logger {
+	return root.With(args...)
+}
+
+// Return a copy of the logger which adds key/value pairs to all messages.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{module: l.module, attrs: append(slices.Clip(l.attrs), args...)}
+}
+
+func (l *Logger) enabled(level syslog.Priority) bool {
+	return enabled(l.module, level)
+}
+
+// Conversion between syslog priorities and slog levels.
+var slogLevels = map[syslog.Priority]slog.Level{
+	syslog.LOG_DEBUG:   slog.LevelDebug,
+	syslog.LOG_INFO:    slog.LevelInfo,
+	syslog.LOG_NOTICE:  slog.LevelInfo + 2,
+	syslog.LOG_WARNING: slog.LevelWarn,
+	syslog.LOG_ERR:     slog.LevelError,
+	syslog.LOG_CRIT:    slog.LevelError + 4,
+}
+
+var levelNames = map[slog.Level]string{
+	slog.LevelDebug:     "debug",
+	slog.LevelInfo:      "info",
+	slog.LevelInfo + 2:  "notice",
+	slog.LevelWarn:      "warning",
+	slog.LevelError:     "error",
+	slog.LevelError + 4: "critical",
+}
+
+var handlerOptions = &slog.HandlerOptions{
+	AddSource: true,
+	Level:     slog.LevelDebug,
+	ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) > 0 {
+			return a
+		}
+		switch a.Key {
+		case slog.TimeKey:
+			a.Key = "timestamp"
+		case slog.LevelKey:
+			if level, ok := a.Value.Any().(slog.Level); ok {
+				a.Value = slog.StringValue(levelNames[level])
+			}
+		case slog.SourceKey:
+			if src, ok := a.Value.Any().(*slog.Source); ok {
+				file := src.File[strings.LastIndexByte(src.File, '/')+1:]
+				a.Value = slog.StringValue(fmt.Sprintf("%s:%d", file, src.Line))
+			}
+		}
+		return a
+	},
+}
+
+// Format the logger fields as a list of key=value pairs.
+func (l *Logger) fields() string {
+	var b strings.Builder
+	for i := 0; i < len(l.attrs); i += 2 {
+		key := fmt.Sprint(l.attrs[i])
+		value := "!MISSING"
+		if i+1 < len(l.attrs) {
+			value = fmt.Sprint(l.attrs[i+1])
+		}
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %s=%s", key, value)
 	}
+	return b.String()
 }
 
-// Write a WARNING message to the log
-func Warningf(message string, args ...any) {
-	if verbosity < syslog.LOG_WARNING {
+// Write a message. The calldepth is the number of stack frames to skip to
+// find the caller which should be reported as source: 1 is the caller of
+// output.
+func (l *Logger) output(calldepth int, prio syslog.Priority, msg string) {
+	msg = strings.TrimSpace(msg)
+
+	if handler != nil {
+		var pcs [1]uintptr
+		runtime.Callers(calldepth+1, pcs[:])
+		r := slog.NewRecord(time.Now(), slogLevels[prio], msg, pcs[0])
+		if l.module != "" {
+			r.AddAttrs(slog.String("module", l.module))
+		}
+		r.Add(l.attrs...)
+		_ = handler.Handle(context.Background(), r)
 		return
 	}
-	msg := format(message, args...)
+
+	msg += l.fields() + "\n"
+
 	if writer != nil {
-		_ = writer.Warning(msg)
-	} else if warning != nil {
-		_ = warning.Output(2, msg)
+		switch prio {
+		case syslog.LOG_DEBUG:
+			_ = writer.Debug(msg)
+		case syslog.LOG_INFO:
+			_ = writer.Info(msg)
+		case syslog.LOG_NOTICE:
+			_ = writer.Notice(msg)
+		case syslog.LOG_WARNING:
+			_ = writer.Warning(msg)
+		case syslog.LOG_ERR:
+			_ = writer.Err(msg)
+		default:
+			_ = writer.Crit(msg)
+		}
+		return
 	}
-}
 
-// Write an ERR message to the log
-func Errf(message string, args ...any) {
-	if verbosity < syslog.LOG_ERR {
-		return
+	var logger *log.Logger
+	switch prio {
+	case syslog.LOG_DEBUG:
+		logger = debug
+	case syslog.LOG_INFO:
+		logger = info
+	case syslog.LOG_NOTICE:
+		logger = notice
+	case syslog.LOG_WARNING:
+		logger = warning
+	case syslog.LOG_ERR:
+		logger = err
+	default:
+		logger = crit
 	}
-	msg := format(message, args...)
-	if writer != nil {
-		_ = writer.Err(msg)
-	} else if err != nil {
-		_ = err.Output(2, msg)
+	if logger != nil {
+		_ = logger.Output(calldepth+1, msg)
 	}
 }
 
-func ErrorLogger() *log.Logger {
-	return err
+// Write a DEBUG message to the log
+func (l *Logger) Debugf(message string, args ...any) {
+	if l.enabled(syslog.LOG_DEBUG) {
+		l.output(2, syslog.LOG_DEBUG, format(message, args...))
+	}
 }
 
-// Write a CRIT message to the log
-func Critf(message string, args ...any) {
-	if verbosity < syslog.LOG_CRIT {
-		return
-	}
-	msg := format(message, args...)
-	if writer != nil {
-		_ = writer.Crit(msg)
-	} else if crit != nil {
-		_ = crit.Output(2, msg)
+// Write an INFO message to the log
+func (l *Logger) Infof(message string, args ...any) {
+	if l.enabled(syslog.LOG_INFO) {
+		l.output(2, syslog.LOG_INFO, format(message, args...))
 	}
 }
 
-type sink struct {
-	calldepth int
+// Write a NOTICE message to the log
+func (l *Logger) Noticef(message string, args ...any) {
+	if l.enabled(syslog.LOG_NOTICE) {
+		l.output(2, syslog.LOG_NOTICE, format(message, args...))
+	}
 }
 
-func (s *sink) Enabled(level int) bool {
-	if level < 0 {
-		level = 0
-	}
-	switch level {
-	case 0:
-		return verbosity >= syslog.LOG_NOTICE
-	case 1:
-		return verbosity >= syslog.LOG_INFO
-	default:
-		return verbosity >= syslog.LOG_DEBUG
+// Write a WARNING message to the log
+func (l *Logger) Warningf(message string, args ...any) {
+	if l.enabled(syslog.LOG_WARNING) {
+		l.output(2, syslog.LOG_WARNING, format(message, args...))
 	}
 }
 
-func (s *sink) Error(e error, msg string, args ...any) {
-	if verbosity < syslog.LOG_ERR {
-		return
-	}
-	message := format("%s: %v", msg, args)
-	if writer != nil {
-		_ = writer.Err(message)
-	} else {
-		_ = err.Output(s.calldepth, message)
+// Write an ERR message to the log
+func (l *Logger) Errf(message string, args ...any) {
+	if l.enabled(syslog.LOG_ERR) {
+		l.output(2, syslog.LOG_ERR, format(message, args...))
 	}
 }
 
-func (s *sink) Info(level int, msg string, args ...any) {
-	if level < 0 {
-		level = 0
-	}
-	message := format("%s: %v", msg, args)
-	switch level {
-	case 0:
-		if writer != nil {
-			_ = writer.Err(message)
-		} else {
-			_ = err.Output(s.calldepth, message)
-		}
-	case 1:
-		if writer != nil {
-			_ = writer.Info(message)
-		} else {
-			_ = info.Output(s.calldepth, message)
-		}
-	default:
-		if writer != nil {
-			_ = writer.Debug(message)
-		} else {
-			_ = debug.Output(s.calldepth, message)
-		}
+// Write a CRIT message to the log
+func (l *Logger) Critf(message string, args ...any) {
+	if l.enabled(syslog.LOG_CRIT) {
+		l.output(2, syslog.LOG_CRIT, format(message, args...))
 	}
 }
 
-func (s *sink) Init(info logr.RuntimeInfo) {
-	s.calldepth = info.CallDepth + 2
+// Write a DEBUG message to the log
+func Debugf(message string, args ...any) {
+	if root.enabled(syslog.LOG_DEBUG) {
+		root.output(2, syslog.LOG_DEBUG, format(message, args...))
+	}
 }
 
-func (s *sink) WithValues(keysAndValues ...any) logr.LogSink {
-	return s
+// Write an INFO message to the log
+func Infof(message string, args ...any) {
+	if root.enabled(syslog.LOG_INFO) {
+		root.output(2, syslog.LOG_INFO, format(message, args...))
+	}
 }
 
-func (s *sink) WithName(name string) logr.LogSink {
-	return s
+// Write a NOTICE message to the log
+func Noticef(message string, args ...any) {
+	if root.enabled(syslog.LOG_NOTICE) {
+		root.output(2, syslog.LOG_NOTICE, format(message, args...))
+	}
 }
 
-func OvsdbLogger() *logr.Logger {
-	l := logr.New(new(sink))
-	return &l
+// Write a WARNING message to the log
+func Warningf(message string, args ...any) {
+	if root.enabled(syslog.LOG_WARNING) {
+		root.output(2, syslog.LOG_WARNING, format(message, args...))
+	}
 }
 
-// used by prometheus error logging
-func (s *sink) Println(args ...any) {
-	msg := fmt.Sprintln(args...)
-	if writer != nil {
-		_ = writer.Err(msg)
-	} else {
-		_ = err.Output(2, msg)
+// Write an ERR message to the log
+func Errf(message string, args ...any) {
+	if root.enabled(syslog.LOG_ERR) {
+		root.output(2, syslog.LOG_ERR, format(message, args...))
 	}
 }
 
-func PrometheusLogger() promhttp.Logger {
-	return new(sink)
+// Write a CRIT message to the log
+func Critf(message string, args ...any) {
+	if root.enabled(syslog.LOG_CRIT) {
+		root.output(2, syslog.LOG_CRIT, format(message, args...))
+	}
 }
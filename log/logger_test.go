@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"log/syslog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevels(t *testing.T) {
+	l, err := ParseLevels("warning, ovsdb=debug,appctl=err")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Level("bridge") != syslog.LOG_WARNING {
+		t.Errorf("unexpected default level: %d", l.Level("bridge"))
+	}
+	if l.Level("ovsdb") != syslog.LOG_DEBUG {
+		t.Errorf("unexpected ovsdb level: %d", l.Level("ovsdb"))
+	}
+	if l.Level("appctl") != syslog.LOG_ERR {
+		t.Errorf("unexpected appctl level: %d", l.Level("appctl"))
+	}
+
+	l, err = ParseLevels("ovsdb=info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Default != syslog.LOG_NOTICE {
+		t.Errorf("unexpected default level: %d", l.Default)
+	}
+
+	for _, s := range []string{"foo", "ovsdb=foo", "=debug"} {
+		if _, err := ParseLevels(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	handler = slog.NewJSONHandler(&buf, handlerOptions)
+	defer func() { handler = nil }()
+	SetLevels(&Levels{
+		Default: syslog.LOG_NOTICE,
+		Modules: map[string]syslog.Priority{"ovsdb": syslog.LOG_DEBUG},
+	})
+
+	Collector("bridge").With("error", errors.New("boom")).Errf("scrape failed")
+	Module("appctl").Debugf("filtered out")
+	Module("ovsdb").With("socket", "/run/openvswitch/db.sock").Debugf("connecting")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d: %s", len(lines), buf.String())
+	}
+
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{
+		"level":     "error",
+		"msg":       "scrape failed",
+		"module":    "bridge",
+		"collector": "bridge",
+		"error":     "boom",
+	} {
+		if rec[k] != v {
+			t.Errorf("%s: expected %q, got %v", k, v, rec[k])
+		}
+	}
+	if _, ok := rec["timestamp"]; !ok {
+		t.Errorf("missing timestamp: %v", rec)
+	}
+	if src, _ := rec["source"].(string); !strings.HasPrefix(src, "logger_test.go:") {
+		t.Errorf("unexpected source: %v", rec["source"])
+	}
+
+	rec = nil
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["level"] != "debug" || rec["socket"] != "/run/openvswitch/db.sock" {
+		t.Errorf("unexpected record: %v", rec)
+	}
+}
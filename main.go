@@ -8,101 +8,284 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/health"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/constlabels"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/relabel"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/otlp"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/remotewrite"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
 )
 
 var format = flag.String("l", "",
-	"List all supported metrics in the specified format and exit.\n"+
+	"List all supported metrics in the specified format and exit. The\n"+
+		"enabled column reflects the current configuration.\n"+
 		"Supported formats are: text, json, csv, tsv, markdown.")
 
 func main() {
 	flag.Parse()
-	if *format != "" {
-		lib.PrintMetrics(collectors.Collectors(), *format)
-		os.Exit(0)
+	if *checkConfigFlag {
+		os.Exit(checkConfig())
 	}
 	if err := config.Parse(); err != nil {
 		// logging not initialized yet, directly write to stderr
 		fmt.Fprintf(os.Stderr, "error: failed to parse config: %s\n", err)
 		os.Exit(1)
 	}
-	if err := log.InitLogging(config.LogLevel()); err != nil {
+	if *replayFlag != "" {
+		if err := replayBackends(*replayFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if *format != "" {
+		lib.PrintMetrics(collectors.Collectors(), *format)
+		os.Exit(0)
+	}
+	if err := log.InitLogging(config.LogLevels(), config.LogFormat()); err != nil {
 		// logging not initialized yet, directly write to stderr
 		fmt.Fprintf(os.Stderr, "error: failed to init log: %s\n", err)
 		os.Exit(1)
 	}
+	if *onceFlag {
+		os.Exit(runOnce(os.Stdout, os.Stderr))
+	}
+	if *supportBundleFlag != "" {
+		os.Exit(writeSupportBundle(*supportBundleFlag))
+	}
 
-	log.Debugf("initializing collectors")
+	handler, err := newMetricsHandler()
+	if err != nil {
+		log.Critf("collector: %s", err)
+		os.Exit(1)
+	}
+	metrics.Store(handler)
+	go handleReload()
+	go lib.Poll(collectors.AllInstances())
+	go remotewrite.Run(gather)
+	go otlp.Run(gather)
 
-	registry := prometheus.NewRegistry()
+	listeners, err := openListeners(config.HttpListen())
+	if err != nil {
+		log.Critf("listen: %s", err)
+		os.Exit(1)
+	}
 
-	for _, c := range collectors.Collectors() {
-		if lib.CollectorEnabled(c) {
-			log.Infof("registering %T", c)
+	// health endpoints do not require authentication
+	plainMux := http.NewServeMux()
+	plainMux.Handle(healthzPath, health.LivenessHandler())
+	plainMux.Handle(readyzPath, health.ReadinessHandler())
+	plainMux.Handle(config.HttpPath(), metrics)
 
-			if err := registry.Register(c); err != nil {
-				log.Critf("collector: %s", err)
-				os.Exit(1)
-			}
-		} else {
-			log.Infof("%T not registered, metric set not enabled", c)
+	plain := &http.Server{Handler: plainMux, ErrorLog: log.ErrorLogger()}
+	secure := &http.Server{ErrorLog: log.ErrorLogger()}
+
+	// TLS listeners are only accepted with a certificate and a key
+	if config.TlsCert() != "" && config.TlsKey() != "" {
+		secure.TLSConfig, err = tlsConfig()
+		if err != nil {
+			log.Critf("tls: %s", err)
+			os.Exit(1)
+		}
+		secure.Handler, err = newSecureMux(metrics)
+		if err != nil {
+			log.Critf("tls: %s", err)
+			os.Exit(1)
 		}
+		certs, err := newCertReloader(config.TlsCert(), config.TlsKey())
+		if err != nil {
+			log.Critf("tls: %s", err)
+			os.Exit(1)
+		}
+		secure.TLSConfig.GetCertificate = certs.GetCertificate
 	}
 
-	handler := promhttp.HandlerFor(
-		registry,
-		promhttp.HandlerOpts{
-			ErrorLog:            log.PrometheusLogger(),
-			ErrorHandling:       promhttp.ContinueOnError,
-			MaxRequestsInFlight: 10,
-			Timeout:             2 * time.Second,
-			EnableOpenMetrics:   true,
-		},
-	)
+	errs := make(chan error)
+	for _, l := range listeners {
+		log.Noticef("listening on %s%s", l.addr, config.HttpPath())
+		go func() {
+			if l.addr.Tls {
+				errs <- secure.ServeTLS(l, "", "")
+			} else {
+				errs <- plain.Serve(l)
+			}
+		}()
+	}
+	log.Critf("listen: %s", <-errs)
+	os.Exit(1)
+}
+
+// Return the handler of TLS listeners. The readiness report exposes socket
+// paths and backend errors, it requires the same authentication as the
+// metrics. The liveness probe only returns a status and is left open.
+func newSecureMux(metrics http.Handler) (*http.ServeMux, error) {
 	mux := http.NewServeMux()
-	mux.Handle(config.HttpPath(), handler)
+	mux.Handle(healthzPath, health.LivenessHandler())
+	for path, handler := range map[string]http.Handler{
+		config.HttpPath(): metrics,
+		readyzPath:        health.ReadinessHandler(),
+	} {
+		handler, err := clientCertHandler(basicAuthHandler(handler))
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle(path, handler)
+	}
+	return mux, nil
+}
+
+// Gather all enabled metrics, as served on the default metrics path.
+func gather() ([]*dto.MetricFamily, error) {
+	gatherer, err := newGatherer(nil, config.METRICS_NONE)
+	if err != nil {
+		return nil, err
+	}
+	return relabel.Gatherer(gatherer).Gather()
+}
 
-	var err error
-	server := http.Server{Addr: config.HttpListen(), Handler: mux, ErrorLog: log.ErrorLogger()}
+// Create a new prometheus gatherer with all enabled collectors of all
+// instances. Each instance gets its own registry and the const-labels and
+// external-id-labels of that instance. The metrics of named instances also
+// get an instance label. If names is not empty, only the collectors with a
+// matching name are registered. If sets is not METRICS_NONE, only the enabled
+// metrics from these sets are exported.
+func newGatherer(names []string, sets config.MetricSet) (prometheus.Gatherer, error) {
+	self := prometheus.NewRegistry()
+	if err := self.Register(selfmetrics.Collector()); err != nil {
+		return nil, err
+	}
+	// The exporter's own metrics are labelled like the first instance.
+	gatherers := prometheus.Gatherers{constlabels.Gatherer(self, instance.All()[0])}
 
-	if config.TlsCert() != "" && config.TlsKey() != "" {
-		log.Noticef("listening on https://%s%s", config.HttpListen(), config.HttpPath())
-		if len(config.AuthUsers()) > 0 {
-			server.Handler = basicAuthHandler(mux)
+	for _, inst := range instance.All() {
+		registry := prometheus.NewRegistry()
+		var reg prometheus.Registerer = registry
+		if inst.Name != "" {
+			reg = prometheus.WrapRegistererWith(
+				prometheus.Labels{"instance": inst.Name}, registry)
+		}
+		for _, c := range collectors.ForInstance(inst) {
+			if !lib.CollectorEnabled(c) {
+				log.Debugf("%T not registered, collector not enabled", c)
+				continue
+			}
+			if len(c.Metrics()) == 0 {
+				log.Debugf("%T not registered, no metrics defined", c)
+				continue
+			}
+			if len(names) > 0 && !slices.Contains(names, c.Name()) {
+				continue
+			}
+			c = lib.Shared(c)
+			if sets != config.METRICS_NONE {
+				c = lib.WithMetricSets(c, sets)
+			}
+			log.Debugf("registering %s", c.Name())
+			if err := reg.Register(c); err != nil {
+				return nil, err
+			}
 		}
-		err = server.ListenAndServeTLS(config.TlsCert(), config.TlsKey())
-	} else {
-		log.Noticef("listening on http://%s%s", config.HttpListen(), config.HttpPath())
-		err = server.ListenAndServe()
+		gatherers = append(gatherers, constlabels.Gatherer(registry, inst))
 	}
+
+	return gatherers, nil
+}
+
+var handlerOpts = promhttp.HandlerOpts{
+	ErrorLog:            log.PrometheusLogger(),
+	ErrorHandling:       promhttp.ContinueOnError,
+	MaxRequestsInFlight: 10,
+	EnableOpenMetrics:   true,
+}
+
+// Limit the number of concurrent filtered scrapes. Each one of them gets its
+// own registry and would otherwise escape MaxRequestsInFlight.
+var filteredInFlight = make(chan struct{}, handlerOpts.MaxRequestsInFlight)
+
+// Return an HTTP handler serving the metrics of all enabled collectors.
+//
+// The collect[] and metric-set query parameters can be used to restrict
+// the scrape to some collectors and metric sets, for example:
+//
+//	/metrics?collect[]=interface&collect[]=pmd-rxq&metric-set=perf
+func newMetricsHandler() (http.Handler, error) {
+	log.Debugf("initializing collectors")
+
+	for _, c := range collectors.Collectors() {
+		if lib.CollectorEnabled(c) {
+			log.Infof("registering %T", c)
+		} else {
+			log.Infof("%T not registered, metric set not enabled", c)
+		}
+	}
+
+	gatherer, err := newGatherer(nil, config.METRICS_NONE)
 	if err != nil {
-		log.Critf("listen: %s", err)
-		os.Exit(1)
+		return nil, err
 	}
-}
+	// Collectors abort their backend requests after scrape-timeout. Leave
+	// them some slack to report their errors before giving up.
+	opts := handlerOpts
+	opts.Timeout = config.ScrapeTimeout() + 1*time.Second
+	handler := promhttp.HandlerFor(relabel.Gatherer(gatherer), opts)
 
-func basicAuthHandler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var user, password, pass string
-		var ok, authorized bool
+		query := r.URL.Query()
+		names := query["collect[]"]
+		setNames := query["metric-set"]
+		if len(names) == 0 && len(setNames) == 0 {
+			handler.ServeHTTP(w, r)
+			return
+		}
 
-		if user, pass, ok = r.BasicAuth(); ok {
-			if password, ok = config.AuthUsers()[user]; ok && pass == password {
-				authorized = true
+		for _, name := range names {
+			if !slices.ContainsFunc(collectors.Collectors(), func(c lib.Collector) bool {
+				return c.Name() == name
+			}) {
+				http.Error(w, fmt.Sprintf("unknown collector: %q", name), http.StatusBadRequest)
+				return
 			}
 		}
-		if authorized {
-			handler.ServeHTTP(w, r)
-		} else {
-			w.Header().Add("WWW-Authenticate", `Basic realm="ovs-node-exporter"`)
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		sets := config.METRICS_NONE
+		if len(setNames) > 0 {
+			s, err := config.ParseMetricSets(strings.Split(strings.Join(setNames, ","), ","))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			sets = s
+		}
+
+		select {
+		case filteredInFlight <- struct{}{}:
+			defer func() { <-filteredInFlight }()
+		default:
+			http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+			return
+		}
+
+		gatherer, err := newGatherer(names, sets)
+		if err != nil {
+			log.Errf("registry: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
-	})
+		promhttp.HandlerFor(relabel.Gatherer(gatherer), opts).ServeHTTP(w, r)
+	}), nil
 }
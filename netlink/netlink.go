@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package netlink reads the network interfaces of the host.
+package netlink
+
+import (
+	"fmt"
+
+	"github.com/jsimonetti/rtnetlink/v2"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
+)
+
+// Backend lists the network interfaces of the host along with the sysfs
+// directory where their attributes can be read.
+type Backend interface {
+	// Links returns all interfaces with their SR-IOV VF information.
+	Links() ([]rtnetlink.LinkMessage, error)
+	SysfsRoot() string
+}
+
+// Client reads the interfaces of the host via rtnetlink.
+type Client struct{}
+
+// NewClient returns a client for the network interfaces of the host.
+func NewClient() *Client {
+	return &Client{}
+}
+
+func (*Client) Links() ([]rtnetlink.LinkMessage, error) {
+	conn, err := rtnetlink.Dial(nil)
+	if err != nil {
+		selfmetrics.BackendError(selfmetrics.Netlink)
+		return nil, fmt.Errorf("failed to connect to rtnetlink: %w", err)
+	}
+	defer conn.Close()
+
+	links, err := conn.Link.ListWithVFInfo()
+	if err != nil {
+		selfmetrics.BackendError(selfmetrics.Netlink)
+		return nil, fmt.Errorf("failed to list interfaces: %w", err)
+	}
+
+	return links, nil
+}
+
+func (*Client) SysfsRoot() string {
+	return "/sys"
+}
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/collectors/lib"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/relabel"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+var (
+	onceFlag = flag.Bool("once", false,
+		"Run the enabled collectors once, print their metrics on stdout and exit.\n"+
+			"The exit status is non-zero if any collector failed.")
+	onceOutput = flag.String("output", "text",
+		"Output format of -once. Supported formats are: text, json.")
+	onceCollectors []string
+)
+
+func init() {
+	flag.Func("collector",
+		"Only run the specified collector with -once. Can be repeated or\n"+
+			"given a comma separated list.",
+		func(s string) error {
+			onceCollectors = append(onceCollectors, strings.Split(s, ",")...)
+			return nil
+		})
+}
+
+type jsonSample struct {
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Value     *float64           `json:"value,omitempty"`
+	Sum       *float64           `json:"sum,omitempty"`
+	Count     *uint64            `json:"count,omitempty"`
+	Buckets   map[string]uint64  `json:"buckets,omitempty"`
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
+}
+
+type jsonFamily struct {
+	Name    string       `json:"name"`
+	Type    string       `json:"type"`
+	Help    string       `json:"help"`
+	Samples []jsonSample `json:"samples"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func jsonFamilies(families []*dto.MetricFamily) []jsonFamily {
+	res := make([]jsonFamily, 0, len(families))
+
+	for _, f := range families {
+		jf := jsonFamily{
+			Name: f.GetName(),
+			Type: strings.ToLower(f.GetType().String()),
+			Help: f.GetHelp(),
+		}
+		for _, m := range f.Metric {
+			s := jsonSample{Labels: make(map[string]string)}
+			for _, l := range m.Label {
+				s.Labels[l.GetName()] = l.GetValue()
+			}
+			switch f.GetType() {
+			case dto.MetricType_COUNTER:
+				s.Value = m.GetCounter().Value
+			case dto.MetricType_GAUGE:
+				s.Value = m.GetGauge().Value
+			case dto.MetricType_UNTYPED:
+				s.Value = m.GetUntyped().Value
+			case dto.MetricType_SUMMARY:
+				s.Sum = m.GetSummary().SampleSum
+				s.Count = m.GetSummary().SampleCount
+				s.Quantiles = make(map[string]float64)
+				for _, q := range m.GetSummary().Quantile {
+					s.Quantiles[formatFloat(q.GetQuantile())] = q.GetValue()
+				}
+			case dto.MetricType_HISTOGRAM:
+				s.Sum = m.GetHistogram().SampleSum
+				s.Count = m.GetHistogram().SampleCount
+				s.Buckets = make(map[string]uint64)
+				for _, b := range m.GetHistogram().Bucket {
+					s.Buckets[formatFloat(b.GetUpperBound())] = b.GetCumulativeCount()
+				}
+			}
+			jf.Samples = append(jf.Samples, s)
+		}
+		res = append(res, jf)
+	}
+
+	return res
+}
+
+func writeFamilies(w io.Writer, families []*dto.MetricFamily, format string) error {
+	switch format {
+	case "text":
+		enc := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+		for _, f := range families {
+			if err := enc.Encode(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(jsonFamilies(families))
+	default:
+		return fmt.Errorf("invalid output format %q", format)
+	}
+}
+
+// Return the names of the collectors which reported a failed scrape, along
+// with their instance if any.
+func failedCollectors(families []*dto.MetricFamily) []string {
+	var failed []string
+
+	for _, f := range families {
+		if f.GetName() != selfmetrics.CollectorSuccessName {
+			continue
+		}
+		for _, m := range f.Metric {
+			if m.GetGauge().GetValue() != 0 {
+				continue
+			}
+			var name, inst string
+			for _, l := range m.Label {
+				switch l.GetName() {
+				case "collector":
+					name = l.GetValue()
+				case "instance":
+					inst = l.GetValue()
+				}
+			}
+			if inst != "" {
+				name = fmt.Sprintf("%s (instance %s)", name, inst)
+			}
+			failed = append(failed, name)
+		}
+	}
+	slices.Sort(failed)
+
+	return failed
+}
+
+// Run the enabled collectors once and print their metrics on stdout. Errors
+// are printed on stderr. Return the process exit status.
+func runOnce(stdout, stderr io.Writer) int {
+	if *onceOutput != "text" && *onceOutput != "json" {
+		fmt.Fprintf(stderr, "error: invalid output format %q. "+
+			"Supported formats are: text, json.\n", *onceOutput)
+		return 1
+	}
+	for _, name := range onceCollectors {
+		i := slices.IndexFunc(collectors.Collectors(), func(c lib.Collector) bool {
+			return c.Name() == name
+		})
+		if i < 0 {
+			fmt.Fprintf(stderr, "error: unknown collector: %q\n", name)
+			return 1
+		}
+		if !lib.CollectorEnabled(collectors.Collectors()[i]) {
+			fmt.Fprintf(stderr, "error: collector not enabled: %q\n", name)
+			return 1
+		}
+	}
+
+	gatherer, err := newGatherer(onceCollectors, config.METRICS_NONE)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	families, err := gatherer.Gather()
+	status := 0
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		status = 1
+	}
+	// relabel rules may drop or rename the collector success series
+	failed := failedCollectors(families)
+	if rules := config.RelabelRules(); len(rules) > 0 {
+		families = relabel.Families(families, rules)
+	}
+	if err := writeFamilies(stdout, families, *onceOutput); err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		status = 1
+	}
+	for _, name := range failed {
+		fmt.Fprintf(stderr, "error: collector failed: %s\n", name)
+		status = 1
+	}
+
+	return status
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl/appctltest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow/openflowtest"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovsdbtest"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gatherSuccess(t *testing.T, values map[string]float64) []*dto.MetricFamily {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	success := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: selfmetrics.CollectorSuccessName,
+		Help: "Whether the last scrape of the collector succeeded.",
+	}, []string{"collector"})
+	registry.MustRegister(success)
+	for name, v := range values {
+		success.WithLabelValues(name).Set(v)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return families
+}
+
+func TestFailedCollectors(t *testing.T) {
+	families := gatherSuccess(t, map[string]float64{
+		"memory": 0, "bridge": 1, "coverage": 0,
+	})
+
+	failed := failedCollectors(families)
+	if !slices.Equal(failed, []string{"coverage", "memory"}) {
+		t.Fatalf("unexpected failed collectors: %v", failed)
+	}
+}
+
+func TestWriteFamiliesJSON(t *testing.T) {
+	families := gatherSuccess(t, map[string]float64{"memory": 1})
+
+	var buf bytes.Buffer
+	if err := writeFamilies(&buf, families, "json"); err != nil {
+		t.Fatal(err)
+	}
+
+	var res []jsonFamily
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Type != "gauge" || len(res[0].Samples) != 1 {
+		t.Fatalf("unexpected result: %s", buf.String())
+	}
+	s := res[0].Samples[0]
+	if s.Labels["collector"] != "memory" || s.Value == nil || *s.Value != 1 {
+		t.Fatalf("unexpected sample: %s", buf.String())
+	}
+}
+
+// Start fake backends in the run directories of the instance used by the
+// collectors. The instances are initialized once per process.
+func onceInstance(t *testing.T) *instance.Instance {
+	t.Helper()
+
+	rundir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	writeConfig(t, path, fmt.Sprintf("ovs-rundir: %[1]s\novn-rundir: %[1]s\novsdb-rundir: %[1]s\n", rundir))
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	inst := instance.All()[0]
+	if inst.OvsRundir != rundir {
+		t.Fatalf("instances already initialized with %s", inst.OvsRundir)
+	}
+
+	db := ovsdbtest.NewServer(t, rundir)
+	db.Insert(t,
+		&ovs.OpenvSwitch{Bridges: []string{"brint"}},
+		&ovs.Bridge{UUID: "brint", Name: "br-int", DatapathType: "system"},
+	)
+	openflowtest.NewBridge(t, rundir, "br-int", openflowtest.Flow{Table: 0, Packets: 1, Bytes: 64})
+	vswitchd := appctltest.NewServer(t, rundir, "ovs-vswitchd")
+	vswitchd.Reply("memory/show", "handlers:29 ports:114 revalidators:11\n")
+	// no reply for coverage/show, the coverage collector fails
+
+	return inst
+}
+
+func runOnceWith(t *testing.T, output string, names ...string) (int, string, string) {
+	t.Helper()
+
+	savedOutput, savedCollectors := *onceOutput, onceCollectors
+	t.Cleanup(func() { *onceOutput, onceCollectors = savedOutput, savedCollectors })
+	*onceOutput, onceCollectors = output, names
+
+	var stdout, stderr bytes.Buffer
+	status := runOnce(&stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRunOnce(t *testing.T) {
+	onceInstance(t)
+
+	status, stdout, stderr := runOnceWith(t, "text", "bridge", "memory")
+	if status != 0 || stderr != "" {
+		t.Fatalf("unexpected status %d: %s", status, stderr)
+	}
+	for _, line := range []string{
+		`ovs_bridge_flow_count{bridge="br-int",datapath_type="system"} 1`,
+		"ovs_memory_ports_total 114",
+		`openstack_network_exporter_collector_success{collector="memory"} 1`,
+	} {
+		if !strings.Contains(stdout, line+"\n") {
+			t.Errorf("%q not found in:\n%s", line, stdout)
+		}
+	}
+
+	status, stdout, stderr = runOnceWith(t, "json", "coverage", "memory")
+	if status != 1 || stderr != "error: collector failed: coverage\n" {
+		t.Fatalf("unexpected status %d: %s", status, stderr)
+	}
+	var families []jsonFamily
+	if err := json.Unmarshal([]byte(stdout), &families); err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(families, func(f jsonFamily) bool {
+		return f.Name == "ovs_memory_ports_total"
+	})
+	if i < 0 || len(families[i].Samples) != 1 || *families[i].Samples[0].Value != 114 {
+		t.Fatalf("unexpected output: %s", stdout)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	writeConfig(t, path, "metric-relabel-configs:\n"+
+		"  - source-labels: [__name__]\n"+
+		"    regex: "+selfmetrics.CollectorSuccessName+"\n"+
+		"    action: drop\n")
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, stderr = runOnceWith(t, "text", "coverage")
+	if status != 1 || stderr != "error: collector failed: coverage\n" {
+		t.Fatalf("unexpected status %d: %s", status, stderr)
+	}
+	if strings.Contains(stdout, selfmetrics.CollectorSuccessName) {
+		t.Fatalf("success series not dropped:\n%s", stdout)
+	}
+
+	status, _, stderr = runOnceWith(t, "text", "foo")
+	if status != 1 || stderr != "error: unknown collector: \"foo\"\n" {
+		t.Fatalf("unexpected status %d: %s", status, stderr)
+	}
+
+	writeConfig(t, path, "collectors: [memory]\n")
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, stderr = runOnceWith(t, "text", "bridge")
+	if status != 1 || stdout != "" || stderr != "error: collector not enabled: \"bridge\"\n" {
+		t.Fatalf("unexpected status %d: %s", status, stderr)
+	}
+}
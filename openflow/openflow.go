@@ -5,6 +5,8 @@ package openflow
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -13,6 +15,7 @@ import (
 	"time"
 
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
 	"github.com/skydive-project/goloxi"
 	"github.com/skydive-project/goloxi/of10"
 )
@@ -73,17 +76,55 @@ type nxAggregateStatsReply struct {
 	Padding     [4]byte
 }
 
-func connect(bridge string) (net.Conn, error) {
-	sock := filepath.Join(config.OvsRundir(), bridge+".mgmt")
+// Backend reads the OpenFlow statistics of the bridges of one OVS instance.
+type Backend interface {
+	// Ping checks that a bridge can be reached. It returns the location of
+	// its management socket, if known.
+	Ping(bridge string) (string, error)
+	AggregateStats(ctx context.Context, bridge string) (*BridgeStats, error)
+	RouterPortsStats(ctx context.Context) ([]RouterPortsStats, error)
+	// AggregateReply and RouterPortsReply return the raw replies decoded by
+	// AggregateStats and RouterPortsStats.
+	AggregateReply(ctx context.Context, bridge string) ([]byte, error)
+	RouterPortsReply(ctx context.Context) ([]byte, error)
+}
+
+// Client queries the OpenFlow management sockets of the bridges of one OVS
+// instance.
+type Client struct {
+	inst *config.Instance
+}
+
+// NewClient returns a client for the bridges of an instance.
+func NewClient(inst *config.Instance) *Client {
+	return &Client{inst: inst}
+}
+
+// Return the path to the OpenFlow management socket of a bridge.
+func (c *Client) SocketPath(bridge string) string {
+	return filepath.Join(c.inst.OvsRundir, bridge+".mgmt")
+}
+
+// Connect to the management socket of a bridge. I/O on the connection fails
+// after the deadline of ctx, if any, and the connection is closed when ctx is
+// done.
+func (c *Client) connect(ctx context.Context, bridge string) (net.Conn, error) {
+	sock := c.SocketPath(bridge)
 
-	conn, err := net.DialTimeout("unix", sock, 1*time.Second)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", sock)
 	if err != nil {
 		return nil, err
 	}
-	err = conn.SetDeadline(time.Now().Add(1 * time.Second))
-	if err != nil {
-		return nil, err
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
 	}
+	context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
 
 	return conn, nil
 }
@@ -119,16 +160,49 @@ func handShake(conn net.Conn) error {
 	return sendRecv(conn, &helloReq, &helloResp)
 }
 
-func (s *BridgeStats) GetAggregateStats() error {
-	conn, err := connect(s.Name)
+func countError(err *error) {
+	if *err != nil {
+		selfmetrics.BackendError(selfmetrics.Openflow)
+	}
+}
+
+// Report the cancellation of ctx instead of the resulting I/O error.
+func wrapContextError(ctx context.Context, err *error) {
+	if *err != nil && ctx.Err() != nil {
+		*err = fmt.Errorf("%w: %w", ctx.Err(), *err)
+	}
+}
+
+// Maximum time spent connecting and exchanging hello messages in Ping.
+const pingTimeout = 1 * time.Second
+
+// Ping checks that the OpenFlow management socket of a bridge accepts
+// connections and answers the initial hello message.
+func (c *Client) Ping(bridge string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	conn, err := c.connect(ctx, bridge)
 	if err != nil {
-		return err
+		return c.SocketPath(bridge), err
+	}
+	defer conn.Close()
+
+	return c.SocketPath(bridge), handShake(conn)
+}
+
+// Send an NX aggregate stats request for all the flows of a bridge and
+// return the raw reply.
+func (c *Client) aggregateReply(ctx context.Context, bridge string) ([]byte, error) {
+	conn, err := c.connect(ctx, bridge)
+	if err != nil {
+		return nil, err
 	}
 	defer conn.Close()
 
 	err = handShake(conn)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	statsReq := nxAggregateStatsRequest{
@@ -144,17 +218,51 @@ func (s *BridgeStats) GetAggregateStats() error {
 		OutPort: ofppNone,
 		TableId: ofpttAll,
 	}
-	var statsResp nxAggregateStatsReply
-	err = sendRecv(conn, &statsReq, &statsResp)
+	err = binary.Write(conn, binary.BigEndian, &statsReq)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	s.Packets = statsResp.PacketCount
-	s.Bytes = statsResp.ByteCount
-	s.Flows = statsResp.FlowCount
+	return readMessage(bufio.NewReader(conn))
+}
 
-	return nil
+// AggregateReply returns the raw NX aggregate stats reply of a bridge, as
+// decoded by AggregateStats.
+func (c *Client) AggregateReply(ctx context.Context, bridge string) (_ []byte, err error) {
+	defer countError(&err)
+	defer wrapContextError(ctx, &err)
+
+	return c.aggregateReply(ctx, bridge)
+}
+
+// AggregateStats returns the packet, byte and flow counters of all the flows
+// of a bridge.
+func (c *Client) AggregateStats(ctx context.Context, bridge string) (_ *BridgeStats, err error) {
+	defer countError(&err)
+	defer wrapContextError(ctx, &err)
+
+	data, err := c.aggregateReply(ctx, bridge)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeAggregateReply(bridge, data)
+}
+
+// DecodeAggregateReply returns the counters of a bridge from a raw NX
+// aggregate stats reply.
+func DecodeAggregateReply(bridge string, data []byte) (*BridgeStats, error) {
+	var statsResp nxAggregateStatsReply
+	err := binary.Read(bytes.NewReader(data), binary.BigEndian, &statsResp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BridgeStats{
+		Name:    bridge,
+		Packets: statsResp.PacketCount,
+		Bytes:   statsResp.ByteCount,
+		Flows:   statsResp.FlowCount,
+	}, nil
 }
 
 type RouterPortsStats struct {
@@ -164,16 +272,44 @@ type RouterPortsStats struct {
 	ByteCount     uint64
 }
 
-func GetRouterPortsStats() ([]RouterPortsStats, error) {
+// RouterPortsReply returns the raw NX flow stats reply of the logical router
+// ports table of the integration bridge, as decoded by RouterPortsStats.
+func (c *Client) RouterPortsReply(ctx context.Context) (_ []byte, err error) {
+	defer countError(&err)
+	defer wrapContextError(ctx, &err)
+
+	return c.flowStatsReply(ctx, c.inst.IntBrdNam, ofTblLogToPhys)
+}
+
+// RouterPortsStats returns the counters of the logical router ports flows of
+// the integration bridge.
+func (c *Client) RouterPortsStats(ctx context.Context) (_ []RouterPortsStats, err error) {
+	defer countError(&err)
+	defer wrapContextError(ctx, &err)
+
+	data, err := c.flowStatsReply(ctx, c.inst.IntBrdNam, ofTblLogToPhys)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRouterPortsReply(data)
+}
+
+// DecodeRouterPortsReply returns the counters of the logical router ports
+// flows from a raw NX flow stats reply.
+func DecodeRouterPortsReply(data []byte) ([]RouterPortsStats, error) {
 	var isDataPathJump bool
 	var routerStats []RouterPortsStats
 	var dpTunnK uint64
 	var pTunnK uint32
 
-	stats, err := getFlowStats(config.IntBrdNam(), ofTblLogToPhys)
+	msg, err := of10.DecodeMessage(data)
 	if err != nil {
 		return nil, err
 	}
+	stats, ok := msg.(*of10.NiciraFlowStatsReply)
+	if !ok {
+		return nil, fmt.Errorf("unexpected openflow response of type %T from bridge", msg)
+	}
 
 	for _, entry := range stats.GetStats() {
 		isDataPathJump = false
@@ -208,17 +344,33 @@ func GetRouterPortsStats() ([]RouterPortsStats, error) {
 	return routerStats, nil
 }
 
-func getFlowStats(bridge string, table uint8) (*of10.NiciraFlowStatsReply, error) {
-
-	conn, err := connect(bridge)
+// Read one OpenFlow message, header included.
+func readMessage(reader *bufio.Reader) ([]byte, error) {
+	data, err := reader.Peek(8)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-	err = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	header := &goloxi.Header{}
+	if err := header.Decode(goloxi.NewDecoder(data)); err != nil {
+		return nil, err
+	}
+	data = make([]byte, header.Length)
+	_, err = io.ReadFull(reader, data)
 	if err != nil {
 		return nil, err
 	}
+	return data, nil
+}
+
+// Send an NX flow stats request for all the flows of a table and return the
+// raw reply.
+func (c *Client) flowStatsReply(ctx context.Context, bridge string, table uint8) ([]byte, error) {
+
+	conn, err := c.connect(ctx, bridge)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
 
 	err = handShake(conn)
 	if err != nil {
@@ -239,28 +391,5 @@ func getFlowStats(bridge string, table uint8) (*of10.NiciraFlowStatsReply, error
 		return nil, err
 	}
 
-	reader := bufio.NewReader(conn)
-	data, err := reader.Peek(8)
-	if err != nil {
-		return nil, err
-	}
-	header := &goloxi.Header{}
-	if err := header.Decode(goloxi.NewDecoder(data)); err != nil {
-		return nil, err
-	}
-	data = make([]byte, header.Length)
-	_, err = io.ReadFull(reader, data)
-	if err != nil {
-		return nil, err
-	}
-	flows, err := of10.DecodeMessage(data)
-	if err != nil {
-		return nil, err
-	}
-	switch t := flows.(type) {
-	case *of10.NiciraFlowStatsReply:
-		return t, nil
-	default:
-		return nil, fmt.Errorf("unexpected openflow response of type %T from bridge", t)
-	}
+	return readMessage(bufio.NewReader(conn))
 }
@@ -0,0 +1,313 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package openflowtest provides a fake OpenFlow management endpoint for
+// testing code which reads the flow statistics of OVS bridges.
+package openflowtest
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/skydive-project/goloxi"
+	"github.com/skydive-project/goloxi/of10"
+)
+
+// required constants taken from openvswitch
+const (
+	ofp10Version       uint8  = 0x01
+	ofptHello          uint8  = 0
+	ofpt10StatsRequest uint8  = 16
+	ofpt10StatsReply   uint8  = 17
+	nxVendorId         uint32 = 0x00002320 // Nicira
+	ofpstVendor        uint16 = 0xffff     // vendor stats
+	ofpttAll           uint8  = 0xff       // all tables
+	nxstFlow           uint32 = 0
+	nxstAggregate      uint32 = 1
+	ofTblLogToPhys     uint8  = 65
+)
+
+// Size of struct nicira10_stats_msg followed by struct nx_flow_stats_request.
+const statsRequestLen = 32
+
+// Flow is an OpenFlow flow with its counters.
+type Flow struct {
+	Table   uint8
+	Match   []goloxi.IOxm
+	Actions []goloxi.IAction
+	Packets uint64
+	Bytes   uint64
+}
+
+// RouterPortFlow returns the flow which OVN installs in the integration
+// bridge for a logical router port.
+func RouterPortFlow(datapath uint64, port uint32, packets, bytes uint64) Flow {
+	metadata := of10.NewOxmMetadata()
+	metadata.Value = datapath
+	reg15 := of10.NewNxmReg15()
+	reg15.Value = port
+
+	return Flow{
+		Table:   ofTblLogToPhys,
+		Match:   []goloxi.IOxm{metadata, reg15},
+		Actions: []goloxi.IAction{of10.NewActionNxClone()},
+		Packets: packets,
+		Bytes:   bytes,
+	}
+}
+
+// Bridge is a fake OpenFlow management endpoint of one bridge. It answers
+// hello messages, NX aggregate stats and NX flow stats requests.
+type Bridge struct {
+	// Path of the management socket.
+	Socket string
+
+	listener net.Listener
+	lock     sync.Mutex
+	flows    []Flow
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewBridge starts a fake management endpoint for bridge name in rundir
+// with the given flows. The endpoint is stopped when the test completes.
+func NewBridge(t testing.TB, rundir, name string, flows ...Flow) *Bridge {
+	t.Helper()
+
+	b := &Bridge{
+		Socket: filepath.Join(rundir, name+".mgmt"),
+		flows:  flows,
+		conns:  make(map[net.Conn]struct{}),
+	}
+
+	l, err := net.Listen("unix", b.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.listener = l
+
+	b.wg.Add(1)
+	go b.serve()
+	t.Cleanup(b.Close)
+
+	return b
+}
+
+// SetFlows replaces the flows of the bridge.
+func (b *Bridge) SetFlows(flows ...Flow) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.flows = flows
+}
+
+// Return the flows of a table or of all tables.
+func (b *Bridge) tableFlows(table uint8) []Flow {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var flows []Flow
+	for _, f := range b.flows {
+		if table == ofpttAll || f.Table == table {
+			flows = append(flows, f)
+		}
+	}
+	return flows
+}
+
+// Close stops the endpoint, closes the open connections and removes its
+// socket.
+func (b *Bridge) Close() {
+	if b.listener.Close() != nil {
+		return
+	}
+	b.lock.Lock()
+	b.closed = true
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.lock.Unlock()
+	b.wg.Wait()
+}
+
+func (b *Bridge) serve() {
+	defer b.wg.Done()
+
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.lock.Lock()
+		if b.closed {
+			b.lock.Unlock()
+			conn.Close()
+			return
+		}
+		b.conns[conn] = struct{}{}
+		b.lock.Unlock()
+		b.wg.Add(1)
+		go b.handleConn(conn)
+	}
+}
+
+func (b *Bridge) handleConn(conn net.Conn) {
+	defer b.wg.Done()
+	defer func() {
+		b.lock.Lock()
+		delete(b.conns, conn)
+		b.lock.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		reply, err := b.handle(msg)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// Read one OpenFlow message, header included.
+func readMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint16(header[2:4])
+	if length < 8 {
+		return nil, errors.New("invalid message length")
+	}
+	msg := make([]byte, length)
+	copy(msg, header)
+	if _, err := io.ReadFull(r, msg[8:]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (b *Bridge) handle(msg []byte) ([]byte, error) {
+	xid := binary.BigEndian.Uint32(msg[4:8])
+
+	switch msg[1] {
+	case ofptHello:
+		reply := make([]byte, 8)
+		reply[0] = ofp10Version
+		reply[1] = ofptHello
+		binary.BigEndian.PutUint16(reply[2:4], 8)
+		binary.BigEndian.PutUint32(reply[4:8], xid)
+		return reply, nil
+
+	case ofpt10StatsRequest:
+		if len(msg) < statsRequestLen ||
+			binary.BigEndian.Uint16(msg[8:10]) != ofpstVendor ||
+			binary.BigEndian.Uint32(msg[12:16]) != nxVendorId {
+			return nil, errors.New("unsupported stats request")
+		}
+		table := msg[28]
+		switch binary.BigEndian.Uint32(msg[16:20]) {
+		case nxstAggregate:
+			return aggregateReply(xid, b.tableFlows(table)), nil
+		case nxstFlow:
+			return flowStatsReply(xid, b.tableFlows(table))
+		}
+	}
+
+	return nil, errors.New("unsupported message")
+}
+
+// Return the header of a Nicira stats reply (struct nicira10_stats_msg),
+// padding included.
+func statsReplyHeader(xid, subtype uint32, length int) []byte {
+	header := make([]byte, 24)
+	header[0] = ofp10Version
+	header[1] = ofpt10StatsReply
+	binary.BigEndian.PutUint16(header[2:4], uint16(length))
+	binary.BigEndian.PutUint32(header[4:8], xid)
+	binary.BigEndian.PutUint16(header[8:10], ofpstVendor)
+	binary.BigEndian.PutUint32(header[12:16], nxVendorId)
+	binary.BigEndian.PutUint32(header[16:20], subtype)
+	return header
+}
+
+func aggregateReply(xid uint32, flows []Flow) []byte {
+	var packets, bytes uint64
+	for _, f := range flows {
+		packets += f.Packets
+		bytes += f.Bytes
+	}
+
+	// struct ofp_aggregate_stats_reply
+	body := make([]byte, 24)
+	binary.BigEndian.PutUint64(body[0:8], packets)
+	binary.BigEndian.PutUint64(body[8:16], bytes)
+	binary.BigEndian.PutUint32(body[16:20], uint32(len(flows)))
+
+	return append(statsReplyHeader(xid, nxstAggregate, 24+len(body)), body...)
+}
+
+// Serialize an object in its own encoder. The generated goloxi code computes
+// the length fields of nested objects from the start of the encoder buffer,
+// they must not share it.
+func serialize(obj goloxi.Serializable) ([]byte, error) {
+	encoder := goloxi.NewEncoder()
+	if err := obj.Serialize(encoder); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
+
+func flowStats(f Flow) ([]byte, error) {
+	var matchLen int
+	for _, oxm := range f.Match {
+		buf, err := serialize(oxm)
+		if err != nil {
+			return nil, err
+		}
+		matchLen += len(buf)
+	}
+
+	stats, err := serialize(&of10.NiciraFlowStats{
+		TableId:     f.Table,
+		MatchLen:    uint16(matchLen),
+		PacketCount: f.Packets,
+		ByteCount:   f.Bytes,
+		Match:       of10.NiciraMatch{NxmEntries: f.Match},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range f.Actions {
+		buf, err := serialize(action)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, buf...)
+	}
+	binary.BigEndian.PutUint16(stats[0:2], uint16(len(stats)))
+
+	return stats, nil
+}
+
+func flowStatsReply(xid uint32, flows []Flow) ([]byte, error) {
+	var reply []byte
+	for _, f := range flows {
+		stats, err := flowStats(f)
+		if err != nil {
+			return nil, err
+		}
+		reply = append(reply, stats...)
+	}
+	return append(statsReplyHeader(xid, nxstFlow, 24+len(reply)), reply...), nil
+}
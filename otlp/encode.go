@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the opentelemetry.proto.collector.metrics.v1
+// ExportMetricsServiceRequest message and its children, as defined in
+// opentelemetry/proto/{collector/metrics,metrics,resource,common}/v1.
+const (
+	requestResourceMetrics = 1
+
+	resourceMetricsResource = 1
+	resourceMetricsScope    = 2
+
+	resourceAttributes = 1
+
+	scopeMetricsScope   = 1
+	scopeMetricsMetrics = 2
+
+	scopeName = 1
+
+	metricName        = 1
+	metricDescription = 2
+	metricGauge       = 5
+	metricSum         = 7
+	metricHistogram   = 9
+	metricSummary     = 11
+
+	// Gauge, Sum, Histogram and Summary
+	dataPoints = 1
+	// Sum and Histogram
+	aggregationTemporality = 2
+	sumIsMonotonic         = 3
+
+	// NumberDataPoint, HistogramDataPoint and SummaryDataPoint
+	pointStartTime = 2
+	pointTime      = 3
+	pointCount     = 4
+	pointSum       = 5
+
+	numberPointDouble     = 4
+	numberPointAttributes = 7
+
+	histogramPointBucketCounts   = 6
+	histogramPointExplicitBounds = 7
+	histogramPointAttributes     = 9
+
+	summaryPointQuantiles  = 6
+	summaryPointAttributes = 7
+
+	quantileQuantile = 1
+	quantileValue    = 2
+
+	keyValueKey   = 1
+	keyValueValue = 2
+
+	anyValueString = 1
+)
+
+// AGGREGATION_TEMPORALITY_CUMULATIVE
+const temporalityCumulative = 2
+
+// Name of the instrumentation scope of all metrics.
+const scope = "github.com/openstack-k8s-operators/openstack-network-exporter"
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	return appendFixed64(b, num, math.Float64bits(v))
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendAttribute(b []byte, num protowire.Number, key, value string) []byte {
+	var v, kv []byte
+	v = appendString(v, anyValueString, value)
+	kv = appendString(kv, keyValueKey, key)
+	kv = appendMessage(kv, keyValueValue, v)
+	return appendMessage(b, num, kv)
+}
+
+func appendLabels(b []byte, num protowire.Number, m *dto.Metric) []byte {
+	for _, l := range m.Label {
+		b = appendAttribute(b, num, l.GetName(), l.GetValue())
+	}
+	return b
+}
+
+func unixNano(t time.Time) uint64 {
+	return uint64(t.UnixNano())
+}
+
+// Return the start and sample times of a data point.
+func pointTimes(m *dto.Metric, start, now time.Time) (uint64, uint64) {
+	if m.TimestampMs != nil {
+		now = time.UnixMilli(m.GetTimestampMs())
+	}
+	return unixNano(start), unixNano(now)
+}
+
+func numberPoint(m *dto.Metric, value float64, start, now time.Time) []byte {
+	var p []byte
+	startNano, nowNano := pointTimes(m, start, now)
+	p = appendFixed64(p, pointStartTime, startNano)
+	p = appendFixed64(p, pointTime, nowNano)
+	p = appendDouble(p, numberPointDouble, value)
+	return appendLabels(p, numberPointAttributes, m)
+}
+
+func histogramPoint(m *dto.Metric, start, now time.Time) []byte {
+	var p, counts, bounds []byte
+	h := m.GetHistogram()
+
+	// prometheus buckets are cumulative, OTLP buckets are not and the
+	// last one is implicitly bounded by +Inf
+	var prev uint64
+	for _, b := range h.Bucket {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		counts = protowire.AppendFixed64(counts, b.GetCumulativeCount()-prev)
+		bounds = protowire.AppendFixed64(bounds, math.Float64bits(b.GetUpperBound()))
+		prev = b.GetCumulativeCount()
+	}
+	counts = protowire.AppendFixed64(counts, h.GetSampleCount()-prev)
+
+	startNano, nowNano := pointTimes(m, start, now)
+	p = appendFixed64(p, pointStartTime, startNano)
+	p = appendFixed64(p, pointTime, nowNano)
+	p = appendFixed64(p, pointCount, h.GetSampleCount())
+	p = appendDouble(p, pointSum, h.GetSampleSum())
+	p = appendMessage(p, histogramPointBucketCounts, counts)
+	p = appendMessage(p, histogramPointExplicitBounds, bounds)
+	return appendLabels(p, histogramPointAttributes, m)
+}
+
+func summaryPoint(m *dto.Metric, start, now time.Time) []byte {
+	var p []byte
+	s := m.GetSummary()
+
+	startNano, nowNano := pointTimes(m, start, now)
+	p = appendFixed64(p, pointStartTime, startNano)
+	p = appendFixed64(p, pointTime, nowNano)
+	p = appendFixed64(p, pointCount, s.GetSampleCount())
+	p = appendDouble(p, pointSum, s.GetSampleSum())
+	for _, q := range s.Quantile {
+		var v []byte
+		v = appendDouble(v, quantileQuantile, q.GetQuantile())
+		v = appendDouble(v, quantileValue, q.GetValue())
+		p = appendMessage(p, summaryPointQuantiles, v)
+	}
+	return appendLabels(p, summaryPointAttributes, m)
+}
+
+// Convert a prometheus metric family to an OTLP Metric message. Counters
+// become monotonic cumulative sums, gauges and untyped metrics become gauges.
+// The start time of cumulative points is the exporter start time.
+func encodeMetric(f *dto.MetricFamily, start, now time.Time) []byte {
+	var data []byte
+	var kind protowire.Number
+
+	switch f.GetType() {
+	case dto.MetricType_COUNTER:
+		kind = metricSum
+		for _, m := range f.Metric {
+			data = appendMessage(data, dataPoints,
+				numberPoint(m, m.GetCounter().GetValue(), start, now))
+		}
+		data = appendVarint(data, aggregationTemporality, temporalityCumulative)
+		data = appendVarint(data, sumIsMonotonic, 1)
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		kind = metricGauge
+		for _, m := range f.Metric {
+			value := m.GetGauge().GetValue()
+			if f.GetType() == dto.MetricType_UNTYPED {
+				value = m.GetUntyped().GetValue()
+			}
+			data = appendMessage(data, dataPoints, numberPoint(m, value, start, now))
+		}
+	case dto.MetricType_HISTOGRAM:
+		kind = metricHistogram
+		for _, m := range f.Metric {
+			data = appendMessage(data, dataPoints, histogramPoint(m, start, now))
+		}
+		data = appendVarint(data, aggregationTemporality, temporalityCumulative)
+	case dto.MetricType_SUMMARY:
+		kind = metricSummary
+		for _, m := range f.Metric {
+			data = appendMessage(data, dataPoints, summaryPoint(m, start, now))
+		}
+	default:
+		return nil
+	}
+
+	var msg []byte
+	msg = appendString(msg, metricName, f.GetName())
+	msg = appendString(msg, metricDescription, f.GetHelp())
+	return appendMessage(msg, kind, data)
+}
+
+// Encode metric families into a serialized ExportMetricsServiceRequest
+// message with a single resource and scope.
+func encode(
+	families []*dto.MetricFamily, attributes map[string]string, start, now time.Time,
+) []byte {
+	var resource, scopeMetrics, instScope, resourceMetrics, req []byte
+
+	for _, k := range slices.Sorted(maps.Keys(attributes)) {
+		resource = appendAttribute(resource, resourceAttributes, k, attributes[k])
+	}
+
+	instScope = appendString(instScope, scopeName, scope)
+	scopeMetrics = appendMessage(scopeMetrics, scopeMetricsScope, instScope)
+	for _, f := range families {
+		if m := encodeMetric(f, start, now); m != nil {
+			scopeMetrics = appendMessage(scopeMetrics, scopeMetricsMetrics, m)
+		}
+	}
+
+	resourceMetrics = appendMessage(resourceMetrics, resourceMetricsResource, resource)
+	resourceMetrics = appendMessage(resourceMetrics, resourceMetricsScope, scopeMetrics)
+	req = appendMessage(req, requestResourceMetrics, resourceMetrics)
+
+	return req
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package otlp exports the metrics to an OpenTelemetry collector using the
+// OTLP protocol over HTTP or gRPC.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/httpclient"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var logger = log.Module("otlp")
+
+// Function which returns the metrics to export.
+type GatherFunc func() ([]*dto.MetricFamily, error)
+
+// How often the settings are checked when export is disabled.
+const idleInterval = 5 * time.Second
+
+// Delay before the first retry, doubled after each failure.
+const minBackoff = 1 * time.Second
+
+// gRPC method of the OTLP metrics service.
+const grpcPath = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+// Error on which the request should be retried.
+type recoverableError struct {
+	error
+}
+
+type exporter struct {
+	settings *config.Otlp
+	client   *http.Client
+	start    time.Time
+}
+
+// Return the active settings. The HTTP client is recreated when the
+// configuration has been reloaded.
+func (e *exporter) refresh() (*config.Otlp, error) {
+	s := config.OtlpSettings()
+	if s == e.settings && e.client != nil {
+		return s, nil
+	}
+
+	client, err := httpclient.New(&s.Tls, s.Timeout)
+	if err != nil {
+		return s, err
+	}
+	if s.Protocol == config.OTLP_GRPC {
+		// gRPC requires HTTP/2, also without TLS
+		transport := client.Transport.(*http.Transport)
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	e.settings = s
+	e.client = client
+
+	return s, nil
+}
+
+// Return the resource attributes. The host name is filled automatically
+// unless it is configured.
+func resourceAttributeValues(s *config.Otlp) map[string]string {
+	attrs := map[string]string{"service.name": "openstack-network-exporter"}
+	if host, err := os.Hostname(); err == nil {
+		attrs["host.name"] = host
+	}
+	for k, v := range s.ResourceAttributes {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+func (e *exporter) newRequest(
+	ctx context.Context, s *config.Otlp, payload []byte,
+) (*http.Request, error) {
+	var req *http.Request
+	var err error
+
+	switch s.Protocol {
+	case config.OTLP_GRPC:
+		u, err := url.Parse(s.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		u.Path = grpcPath
+		// length-prefixed message, not compressed
+		body := make([]byte, 5, 5+len(payload))
+		binary.BigEndian.PutUint32(body[1:], uint32(len(payload)))
+		body = append(body, payload...)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/grpc+proto")
+		req.Header.Set("TE", "trailers")
+	default:
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	}
+	req.Header.Set("User-Agent", "openstack-network-exporter")
+
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	auth, err := httpclient.Authorization(&s.Credentials)
+	if err != nil {
+		return nil, recoverableError{err}
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	return req, nil
+}
+
+// gRPC status codes which are worth retrying, as listed in the OTLP
+// specification.
+var grpcRetryable = map[int]bool{
+	1:  true, // CANCELLED
+	4:  true, // DEADLINE_EXCEEDED
+	8:  true, // RESOURCE_EXHAUSTED
+	10: true, // ABORTED
+	11: true, // OUT_OF_RANGE
+	14: true, // UNAVAILABLE
+	15: true, // DATA_LOSS
+}
+
+func grpcStatus(resp *http.Response) error {
+	// the status is in the trailers, or in the headers for responses
+	// without a body
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("invalid grpc-status: %q", status)
+	}
+	if code == 0 {
+		return nil
+	}
+	err = fmt.Errorf("grpc-status %d: %s", code, message)
+	if grpcRetryable[code] {
+		return recoverableError{err}
+	}
+	return err
+}
+
+func (e *exporter) send(payload []byte) error {
+	s, err := e.refresh()
+	if err != nil {
+		return recoverableError{err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	req, err := e.newRequest(ctx, s, payload)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return recoverableError{err}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK && s.Protocol == config.OTLP_GRPC:
+		return grpcStatus(resp)
+	case resp.StatusCode/100 == 2:
+		return nil
+	}
+
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return recoverableError{err}
+	}
+	return err
+}
+
+// Send the payload, retrying recoverable errors with an exponential backoff
+// until the deadline.
+func (e *exporter) export(payload []byte, deadline time.Time) {
+	backoff := minBackoff
+	for {
+		err := e.send(payload)
+		if err == nil {
+			selfmetrics.OtlpExported()
+			return
+		}
+		if !errors.As(err, new(recoverableError)) || time.Now().Add(backoff).After(deadline) {
+			logger.With("error", err).Errf("otlp: export failed")
+			selfmetrics.OtlpFailed()
+			return
+		}
+		logger.With("error", err).Warningf("otlp: retrying in %s", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Run gathers metrics at the configured interval and exports them to the
+// OTLP collector. Failed exports are retried until the next interval.
+// Settings are read from the active configuration and follow reloads.
+// Nothing is exported while the endpoint is empty. Run never returns.
+func Run(gather GatherFunc) {
+	e := &exporter{start: time.Now()}
+
+	for {
+		s := config.OtlpSettings()
+		if s.Endpoint == "" {
+			time.Sleep(idleInterval)
+			continue
+		}
+
+		now := time.Now()
+		families, err := gather()
+		if err != nil {
+			logger.With("error", err).Errf("otlp: gather")
+		}
+		if len(families) > 0 {
+			payload := encode(families, resourceAttributeValues(s), e.start, now)
+			e.export(payload, now.Add(s.Interval))
+		}
+
+		time.Sleep(time.Until(now.Add(s.Interval)))
+	}
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func loadConfig(t *testing.T, yaml string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Return the raw values of the fields with the given number.
+func field(t *testing.T, b []byte, want protowire.Number) [][]byte {
+	t.Helper()
+	var res [][]byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		if num == want {
+			v := b[:n]
+			if typ == protowire.BytesType {
+				v, _ = protowire.ConsumeBytes(v)
+			}
+			res = append(res, v)
+		}
+		b = b[n:]
+	}
+	return res
+}
+
+func gatherTest() []*dto.MetricFamily {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ovs_test_total", Help: "test counter",
+	}, []string{"bridge"})
+	counter.WithLabelValues("br-int").Add(7)
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ovs_test_gauge", Help: "test gauge",
+	})
+	gauge.Set(3)
+	registry.MustRegister(counter, gauge)
+	families, err := registry.Gather()
+	if err != nil {
+		panic(err)
+	}
+	return families
+}
+
+// Check the content of an ExportMetricsServiceRequest built from gatherTest.
+func checkRequest(t *testing.T, req []byte) {
+	t.Helper()
+
+	rm := field(t, req, requestResourceMetrics)
+	if len(rm) != 1 {
+		t.Fatalf("expected 1 resource, got %d", len(rm))
+	}
+	resource := field(t, rm[0], resourceMetricsResource)[0]
+	attrs := make(map[string]string)
+	for _, kv := range field(t, resource, resourceAttributes) {
+		key := string(field(t, kv, keyValueKey)[0])
+		value := field(t, field(t, kv, keyValueValue)[0], anyValueString)[0]
+		attrs[key] = string(value)
+	}
+	if attrs["ovn.chassis.name"] != "chassis-0" {
+		t.Errorf("unexpected resource attributes: %v", attrs)
+	}
+
+	sm := field(t, rm[0], resourceMetricsScope)[0]
+	metrics := field(t, sm, scopeMetricsMetrics)
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 metrics, got %d", len(metrics))
+	}
+	for _, m := range metrics {
+		name := string(field(t, m, metricName)[0])
+		switch name {
+		case "ovs_test_gauge":
+			if len(field(t, m, metricGauge)) != 1 {
+				t.Errorf("%s: not a gauge", name)
+			}
+		case "ovs_test_total":
+			sum := field(t, m, metricSum)
+			if len(sum) != 1 {
+				t.Fatalf("%s: not a sum", name)
+			}
+			temporality, _ := protowire.ConsumeVarint(field(t, sum[0], aggregationTemporality)[0])
+			if temporality != temporalityCumulative {
+				t.Errorf("%s: unexpected temporality %d", name, temporality)
+			}
+			point := field(t, sum[0], dataPoints)[0]
+			attr := field(t, point, numberPointAttributes)[0]
+			if string(field(t, attr, keyValueKey)[0]) != "bridge" {
+				t.Errorf("%s: missing bridge attribute", name)
+			}
+		default:
+			t.Errorf("unexpected metric: %s", name)
+		}
+	}
+}
+
+func TestExportHTTP(t *testing.T) {
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected content type: %s", r.Header.Get("Content-Type"))
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	t.Setenv("TEST_CHASSIS", "chassis-0")
+	loadConfig(t, `
+otlp:
+  endpoint: `+server.URL+`/v1/metrics
+  resource-attributes:
+    ovn.chassis.name: ${TEST_CHASSIS}
+`)
+
+	e := &exporter{start: time.Now()}
+	s := config.OtlpSettings()
+	err := e.send(encode(gatherTest(), resourceAttributeValues(s), e.start, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRequest(t, body)
+}
+
+func TestExportGRPC(t *testing.T) {
+	var body []byte
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("unexpected protocol: %s", r.Proto)
+		}
+		if r.URL.Path != grpcPath {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		buf, _ := io.ReadAll(r.Body)
+		if len(buf) < 5 || int(binary.BigEndian.Uint32(buf[1:5])) != len(buf)-5 {
+			t.Errorf("invalid grpc message framing")
+		} else {
+			body = buf[5:]
+		}
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		// empty ExportMetricsServiceResponse
+		_, _ = w.Write([]byte{0, 0, 0, 0, 0})
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	t.Setenv("TEST_CHASSIS", "chassis-0")
+	loadConfig(t, `
+otlp:
+  endpoint: `+server.URL+`
+  protocol: grpc
+  resource-attributes:
+    ovn.chassis.name: ${TEST_CHASSIS}
+`)
+
+	e := &exporter{start: time.Now()}
+	s := config.OtlpSettings()
+	err := e.send(encode(gatherTest(), resourceAttributeValues(s), e.start, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRequest(t, body)
+}
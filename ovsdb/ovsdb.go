@@ -5,141 +5,244 @@ package ovsdb
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"net"
+	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
 	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
 	"github.com/ovn-kubernetes/libovsdb/client"
+	"github.com/ovn-kubernetes/libovsdb/mapper"
 	"github.com/ovn-kubernetes/libovsdb/model"
 	"github.com/ovn-kubernetes/libovsdb/ovsdb"
 )
 
-var (
-	ovsdbLock  sync.Mutex
-	ovsdbConn  client.Client
-	ovsdbModel model.DatabaseModel
-)
+var logger = log.Module("ovsdb")
 
-func connect(ctx context.Context) (client.Client, error) {
-	ovsdbLock.Lock()
-	defer ovsdbLock.Unlock()
+// Backend reads the Open_vSwitch database of one OVS instance.
+type Backend interface {
+	// Rows returns all rows of a table in OVSDB notation.
+	Rows(ctx context.Context, table string) ([]ovsdb.Row, error)
+	// Ping checks that the database can be reached. It returns the
+	// location of its socket, if known.
+	Ping() (string, error)
+}
 
-	if ovsdbConn != nil {
-		return ovsdbConn, nil
+// Maximum time spent connecting to the database in Ping.
+const pingTimeout = 1 * time.Second
+
+// Client is a connection to the Open_vSwitch database of one OVS instance.
+// It is established on first use and kept open.
+type Client struct {
+	inst   *config.Instance
+	logger *log.Logger
+	lock   sync.Mutex
+	conn   client.Client
+}
+
+// NewClient returns a client for the ovsdb-server of an instance.
+func NewClient(inst *config.Instance) *Client {
+	l := logger
+	if inst.Name != "" {
+		l = l.With("instance", inst.Name)
 	}
+	return &Client{inst: inst, logger: l}
+}
 
-	endpoint := fmt.Sprintf("unix:%s/db.sock", config.OvsRundir())
+// Return the path to the ovsdb-server socket.
+func (c *Client) SocketPath() string {
+	return filepath.Join(c.inst.OvsRundir, "db.sock")
+}
 
-	log.Debugf("connecting to ovsdb: %s", endpoint)
+// Ping checks that the ovsdb-server socket accepts connections.
+func (c *Client) Ping() (string, error) {
+	socket := c.SocketPath()
+	conn, err := net.DialTimeout("unix", socket, pingTimeout)
+	if err != nil {
+		return socket, err
+	}
+	return socket, conn.Close()
+}
+
+func (c *Client) connect(ctx context.Context) (client.Client, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	endpoint := "unix:" + c.SocketPath()
+
+	l := c.logger.With("socket", c.SocketPath())
+	l.Debugf("connecting to ovsdb")
 
 	schema, err := ovs.FullDatabaseModel()
 	if err != nil {
-		log.Errf("NewOVSDBClient: %s", err)
-		return nil, err
-	}
-	mod, errs := model.NewDatabaseModel(ovs.Schema(), schema)
-	if len(errs) > 0 {
-		for _, err = range errs {
-			log.Errf("model.NewDatabaseModel: %s", err)
-		}
+		l.With("error", err).Errf("NewOVSDBClient")
 		return nil, err
 	}
 
 	db, err := client.NewOVSDBClient(
 		schema,
 		client.WithEndpoint(endpoint),
-		client.WithLogger(log.OvsdbLogger()),
+		client.WithLogger(c.ovsdbLogger()),
 	)
 	if err != nil {
-		log.Errf("NewOVSDBClient: %s", err)
+		l.With("error", err).Errf("NewOVSDBClient")
 		return nil, err
 	}
 	if err = db.Connect(ctx); err != nil {
-		log.Errf("db.Connect: %s", err)
+		l.With("error", err).Errf("db.Connect")
 		return nil, err
 	}
 
-	ovsdbModel = mod
-	ovsdbConn = db
+	c.conn = db
 
 	return db, nil
 }
 
-func Get(ctx context.Context, result model.Model) error {
-	db, err := connect(ctx)
-	if err != nil {
-		log.Errf("connect: %s", err)
-		return err
+func (c *Client) ovsdbLogger() *logr.Logger {
+	kv := []any{"socket", c.SocketPath()}
+	if c.inst.Name != "" {
+		kv = append(kv, "instance", c.inst.Name)
 	}
+	return log.OvsdbLogger(kv...)
+}
 
-	info, err := ovsdbModel.NewModelInfo(result)
+// Rows returns all rows of a table in OVSDB notation.
+func (c *Client) Rows(ctx context.Context, table string) ([]ovsdb.Row, error) {
+	l := c.logger.With("socket", c.SocketPath())
+
+	db, err := c.connect(ctx)
 	if err != nil {
-		log.Errf("NewModelInfo: %s", err)
-		return err
+		l.With("error", err).Errf("connect")
+		return nil, err
 	}
+
 	res, err := db.Transact(ctx, ovsdb.Operation{
 		Op:    ovsdb.OperationSelect,
-		Table: info.Metadata.TableName,
+		Table: table,
 	})
 	if err != nil {
-		log.Errf("Transact: %s", err)
-		return err
+		l.With("error", err).Errf("Transact")
+		return nil, err
 	}
+
+	var rows []ovsdb.Row
 	for _, r := range res {
-		for _, row := range r.Rows {
-			err = info.SetField("_uuid", row["_uuid"].(ovsdb.UUID).GoUUID)
-			if err != nil {
-				log.Errf("info.SetField: %s", err)
-				return err
-			}
-			return ovsdbModel.Mapper.GetRowData(&row, info) //nolint: staticcheck // the surrounding loop is unconditionally terminated
+		rows = append(rows, r.Rows...)
+	}
+
+	return rows, nil
+}
+
+var (
+	modelOnce sync.Once
+	dbModel   model.DatabaseModel
+	modelErr  error
+)
+
+// Return the model of the Open_vSwitch database used to map rows to the
+// structs of the ovs package.
+func databaseModel() (model.DatabaseModel, error) {
+	modelOnce.Do(func() {
+		schema, err := ovs.FullDatabaseModel()
+		if err != nil {
+			modelErr = err
+			return
 		}
+		var errs []error
+		dbModel, errs = model.NewDatabaseModel(ovs.Schema(), schema)
+		modelErr = errors.Join(errs...)
+	})
+	return dbModel, modelErr
+}
+
+func countError(err *error) {
+	if *err != nil {
+		selfmetrics.BackendError(selfmetrics.Ovsdb)
+	}
+}
+
+// Fill a model from a row.
+func setRow(dbModel model.DatabaseModel, row *ovsdb.Row, info *mapper.Info) error {
+	if err := dbModel.Mapper.GetRowData(row, info); err != nil {
+		logger.With("error", err).Errf("Mapper.GetRowData")
+		return err
+	}
+	uuid, ok := (*row)["_uuid"].(ovsdb.UUID)
+	if !ok {
+		err := errors.New("missing _uuid column")
+		logger.With("error", err).Errf("info.SetField")
+		return err
+	}
+	if err := info.SetField("_uuid", uuid.GoUUID); err != nil {
+		logger.With("error", err).Errf("info.SetField")
+		return err
+	}
+	return nil
+}
+
+// Get reads the first row of the table of result.
+func Get(ctx context.Context, b Backend, result model.Model) (err error) {
+	defer countError(&err)
+
+	dbModel, err := databaseModel()
+	if err != nil {
+		logger.With("error", err).Errf("model.NewDatabaseModel")
+		return err
+	}
+	info, err := dbModel.NewModelInfo(result)
+	if err != nil {
+		logger.With("error", err).Errf("NewModelInfo")
+		return err
+	}
+	rows, err := b.Rows(ctx, info.Metadata.TableName)
+	if err != nil {
+		return err
 	}
-	return client.ErrNotFound
+	if len(rows) == 0 {
+		return client.ErrNotFound
+	}
+
+	return setRow(dbModel, &rows[0], info)
 }
 
-func List[T model.Model](ctx context.Context, results *[]T) error {
-	db, err := connect(ctx)
+// List reads all rows of the table of T.
+func List[T model.Model](ctx context.Context, b Backend, results *[]T) (err error) {
+	defer countError(&err)
+
+	dbModel, err := databaseModel()
 	if err != nil {
-		log.Errf("connect: %s", err)
+		logger.With("error", err).Errf("model.NewDatabaseModel")
 		return err
 	}
 
 	var t T
 
-	info, err := ovsdbModel.NewModelInfo(&t)
+	info, err := dbModel.NewModelInfo(&t)
 	if err != nil {
-		log.Errf("NewModelInfo: %s", err)
+		logger.With("error", err).Errf("NewModelInfo")
 		return err
 	}
-
-	res, err := db.Transact(ctx, ovsdb.Operation{
-		Op:    ovsdb.OperationSelect,
-		Table: info.Metadata.TableName,
-	})
+	rows, err := b.Rows(ctx, info.Metadata.TableName)
 	if err != nil {
-		log.Errf("Transact: %s", err)
 		return err
 	}
 
-	for _, r := range res {
-		for _, row := range r.Rows {
-			var value T
-			info, _ = ovsdbModel.NewModelInfo(&value)
-			err = ovsdbModel.Mapper.GetRowData(&row, info)
-			if err != nil {
-				log.Errf("Mapper.GetRowData: %s", err)
-				return err
-			}
-			err = info.SetField("_uuid", row["_uuid"].(ovsdb.UUID).GoUUID)
-			if err != nil {
-				log.Errf("info.SetField: %s", err)
-				return err
-			}
-			*results = append(*results, value)
+	for _, row := range rows {
+		var value T
+		info, _ = dbModel.NewModelInfo(&value)
+		if err = setRow(dbModel, &row, info); err != nil {
+			return err
 		}
+		*results = append(*results, value)
 	}
 	return nil
 }
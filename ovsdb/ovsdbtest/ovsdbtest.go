@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ovsdbtest provides an in-memory Open_vSwitch database server for
+// testing code which reads OVSDB.
+package ovsdbtest
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+	"github.com/ovn-kubernetes/libovsdb/client"
+	"github.com/ovn-kubernetes/libovsdb/database/inmemory"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+	"github.com/ovn-kubernetes/libovsdb/server"
+)
+
+// Server is an in-memory ovsdb-server serving the Open_vSwitch database.
+type Server struct {
+	// Path of the database socket.
+	Socket string
+
+	srv    *server.OvsdbServer
+	schema model.ClientDBModel
+}
+
+// Maximum time waiting for the server to listen.
+const readyTimeout = 5 * time.Second
+
+// NewServer starts an empty Open_vSwitch database server listening on
+// db.sock in rundir. The server is stopped when the test completes.
+func NewServer(t testing.TB, rundir string) *Server {
+	t.Helper()
+
+	schema, err := ovs.FullDatabaseModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbModel, errs := model.NewDatabaseModel(ovs.Schema(), schema)
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logr.Discard()
+	db := inmemory.NewDatabase(map[string]model.ClientDBModel{
+		schema.Name(): schema,
+	}, &logger)
+
+	srv, err := server.NewOvsdbServer(db, &logger, dbModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		Socket: filepath.Join(rundir, "db.sock"),
+		srv:    srv,
+		schema: schema,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve("unix", s.Socket)
+	}()
+	t.Cleanup(srv.Close)
+
+	deadline := time.Now().Add(readyTimeout)
+	for !srv.Ready() {
+		select {
+		case err := <-errc:
+			t.Fatal(err)
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ovsdb server not ready")
+		}
+	}
+
+	return s
+}
+
+// Insert creates rows in a single transaction. Rows may reference each other
+// via named UUIDs set in their UUID field. Like with a real ovsdb-server,
+// rows of non-root tables which are not referenced are garbage collected.
+func (s *Server) Insert(t testing.TB, models ...model.Model) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+
+	db, err := client.NewOVSDBClient(s.schema, client.WithEndpoint("unix:"+s.Socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var ops []ovsdb.Operation
+	for _, m := range models {
+		op, err := db.Create(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops = append(ops, op...)
+	}
+	res, err := db.Transact(ctx, ops...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ovsdb.CheckOperationResults(res, ops); err != nil {
+		t.Fatal(err)
+	}
+}
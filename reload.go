@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+)
+
+// How often the configuration file is checked for modifications.
+const configWatchInterval = 5 * time.Second
+
+// HTTP handler that forwards requests to the handler of the most recently
+// loaded configuration. Requests are blocked while the configuration and the
+// handler are being swapped so that they never see one without the other.
+type swappableHandler struct {
+	lock    sync.RWMutex
+	handler http.Handler
+}
+
+func (s *swappableHandler) Store(h http.Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.handler = h
+}
+
+func (s *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	s.handler.ServeHTTP(w, r)
+}
+
+// Activate cfg and build its handler with build. If building the handler
+// fails, the previous configuration is restored and the current handler is
+// kept.
+func (s *swappableHandler) Swap(cfg *config.Config, build func() (http.Handler, error)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prev := config.Activate(cfg)
+	h, err := build()
+	if err != nil {
+		config.Activate(prev)
+		return err
+	}
+	s.handler = h
+	return nil
+}
+
+var metrics = new(swappableHandler)
+
+// Settings which are only read on startup. Changing them requires a restart.
+type staticSettings struct {
+	httpListen, httpPath, tlsCert, tlsKey string
+	tlsClientCa                           string
+	tlsClientAuth                         config.ClientAuth
+	ovsRundir, ovnRundir, ovsdbRundir     string
+	ovsProcdir, intBrdNam                 string
+	logFormat                             string
+	instances                             string
+}
+
+func currentStaticSettings() staticSettings {
+	return staticSettings{
+		httpListen:    fmt.Sprint(config.HttpListen()),
+		httpPath:      config.HttpPath(),
+		tlsCert:       config.TlsCert(),
+		tlsKey:        config.TlsKey(),
+		tlsClientCa:   config.TlsClientCa(),
+		tlsClientAuth: config.TlsClientAuth(),
+		ovsRundir:     config.OvsRundir(),
+		ovnRundir:     config.OvnRundir(),
+		ovsdbRundir:   config.OvsdbRundir(),
+		ovsProcdir:    config.OvsProcdir(),
+		intBrdNam:     config.IntBrdNam(),
+		logFormat:     config.LogFormat(),
+		instances:     instancesSetting(),
+	}
+}
+
+// Return the instances settings in a comparable form.
+func instancesSetting() string {
+	var res []string
+	for _, i := range config.Instances() {
+		res = append(res, fmt.Sprintf("%+v", *i))
+	}
+	return strings.Join(res, ",")
+}
+
+var reloadLock sync.Mutex
+
+// Builds the metrics handler of the active configuration on reload.
+var buildMetricsHandler = newMetricsHandler
+
+// Re-read the configuration and swap the metrics registry. If the new
+// configuration is invalid, the current one is kept.
+func reload() {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	log.Noticef("reloading configuration")
+
+	before := currentStaticSettings()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Errf("reload: invalid configuration, keeping current one: %s", err)
+		return
+	}
+	if err := metrics.Swap(cfg, buildMetricsHandler); err != nil {
+		log.Errf("reload: %s, keeping current configuration", err)
+		return
+	}
+	log.SetLevels(config.LogLevels())
+
+	if currentStaticSettings() != before {
+		log.Warningf("reload: http, tls, rundir, instances and log format settings require a restart to take effect")
+	}
+}
+
+// Reload the configuration on SIGHUP and when the configuration file changes.
+func handleReload() {
+	go config.Watch(configWatchInterval, reload)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		reload()
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+)
+
+func writeConfig(t *testing.T, path, yaml string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func handlerReplying(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	})
+}
+
+func serve(h http.Handler) string {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	writeConfig(t, path, "scrape-timeout: 3s\n")
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	metrics.Store(handlerReplying("old"))
+	t.Cleanup(func() { buildMetricsHandler = newMetricsHandler })
+
+	// the handler is built from the new configuration, but fails
+	writeConfig(t, path, "scrape-timeout: 5s\n")
+	var seen time.Duration
+	buildMetricsHandler = func() (http.Handler, error) {
+		seen = config.ScrapeTimeout()
+		return nil, errors.New("duplicate metrics collector registration attempted")
+	}
+	reload()
+	if seen != 5*time.Second {
+		t.Fatalf("handler not built from the new configuration: %s", seen)
+	}
+	if d := config.ScrapeTimeout(); d != 3*time.Second {
+		t.Fatalf("new configuration activated: %s", d)
+	}
+	if body := serve(metrics); body != "old" {
+		t.Fatalf("handler replaced: %q", body)
+	}
+
+	buildMetricsHandler = func() (http.Handler, error) {
+		return handlerReplying("new"), nil
+	}
+	reload()
+	if d := config.ScrapeTimeout(); d != 5*time.Second {
+		t.Fatalf("new configuration not activated: %s", d)
+	}
+	if body := serve(metrics); body != "new" {
+		t.Fatalf("handler not replaced: %q", body)
+	}
+}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package remotewrite
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the prometheus.WriteRequest protobuf message and its
+// children, as defined in prometheus/prompb/{remote,types}.proto.
+const (
+	writeRequestTimeseries = 1
+	writeRequestMetadata   = 3
+
+	timeSeriesLabels  = 1
+	timeSeriesSamples = 2
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+
+	metadataType       = 1
+	metadataFamilyName = 2
+	metadataHelp       = 4
+)
+
+// Values of the prometheus.MetricMetadata.MetricType enum.
+var metadataTypes = map[dto.MetricType]uint64{
+	dto.MetricType_COUNTER:   1,
+	dto.MetricType_GAUGE:     2,
+	dto.MetricType_HISTOGRAM: 3,
+	dto.MetricType_SUMMARY:   5,
+}
+
+type label struct {
+	name, value string
+}
+
+type series struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+func appendLabel(b []byte, l label) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, labelName, protowire.BytesType)
+	msg = protowire.AppendString(msg, l.name)
+	msg = protowire.AppendTag(msg, labelValue, protowire.BytesType)
+	msg = protowire.AppendString(msg, l.value)
+
+	b = protowire.AppendTag(b, timeSeriesLabels, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendSeries(b []byte, s *series) []byte {
+	var msg []byte
+	for _, l := range s.labels {
+		msg = appendLabel(msg, l)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, sampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+	sample = protowire.AppendTag(sample, sampleTimestamp, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+	msg = protowire.AppendTag(msg, timeSeriesSamples, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, sample)
+
+	b = protowire.AppendTag(b, writeRequestTimeseries, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendMetadata(b []byte, f *dto.MetricFamily) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, metadataType, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, metadataTypes[f.GetType()])
+	msg = protowire.AppendTag(msg, metadataFamilyName, protowire.BytesType)
+	msg = protowire.AppendString(msg, f.GetName())
+	msg = protowire.AppendTag(msg, metadataHelp, protowire.BytesType)
+	msg = protowire.AppendString(msg, f.GetHelp())
+
+	b = protowire.AppendTag(b, writeRequestMetadata, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// Build the labels of a series from its metric labels. The labels are sorted
+// by name as required by the remote_write specification.
+func seriesLabels(name string, m *dto.Metric, extra ...label) []label {
+	labels := []label{{model.MetricNameLabel, name}}
+	for _, l := range m.Label {
+		labels = append(labels, label{l.GetName(), l.GetValue()})
+	}
+	labels = append(labels, extra...)
+	slices.SortFunc(labels, func(a, b label) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return labels
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Flatten a metric family into individual series. Histograms and summaries
+// are split in the same way as in the prometheus text format.
+func familySeries(f *dto.MetricFamily, now time.Time) []series {
+	var res []series
+	name := f.GetName()
+
+	for _, m := range f.Metric {
+		ts := now.UnixMilli()
+		if m.TimestampMs != nil {
+			ts = m.GetTimestampMs()
+		}
+		add := func(name string, value float64, extra ...label) {
+			res = append(res, series{
+				labels:    seriesLabels(name, m, extra...),
+				value:     value,
+				timestamp: ts,
+			})
+		}
+
+		switch f.GetType() {
+		case dto.MetricType_COUNTER:
+			add(name, m.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			add(name, m.GetGauge().GetValue())
+		case dto.MetricType_UNTYPED:
+			add(name, m.GetUntyped().GetValue())
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			for _, q := range s.Quantile {
+				add(name, q.GetValue(),
+					label{model.QuantileLabel, formatFloat(q.GetQuantile())})
+			}
+			add(name+"_sum", s.GetSampleSum())
+			add(name+"_count", float64(s.GetSampleCount()))
+		case dto.MetricType_HISTOGRAM:
+			h := m.GetHistogram()
+			infSeen := false
+			for _, b := range h.Bucket {
+				if math.IsInf(b.GetUpperBound(), 1) {
+					infSeen = true
+				}
+				add(name+"_bucket", float64(b.GetCumulativeCount()),
+					label{model.BucketLabel, formatFloat(b.GetUpperBound())})
+			}
+			if !infSeen {
+				add(name+"_bucket", float64(h.GetSampleCount()),
+					label{model.BucketLabel, "+Inf"})
+			}
+			add(name+"_sum", h.GetSampleSum())
+			add(name+"_count", float64(h.GetSampleCount()))
+		}
+	}
+
+	return res
+}
+
+// Encode metric families into a serialized prometheus.WriteRequest message.
+// Metrics without an explicit timestamp are stamped with now.
+func encode(families []*dto.MetricFamily, now time.Time) []byte {
+	var b []byte
+	for _, f := range families {
+		for _, s := range familySeries(f, now) {
+			b = appendSeries(b, &s)
+		}
+	}
+	for _, f := range families {
+		b = appendMetadata(b, f)
+	}
+	return b
+}
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package remotewrite pushes the exported metrics to a remote server using
+// the prometheus remote_write protocol.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/httpclient"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var logger = log.Module("remote-write")
+
+// Function which returns the metrics to push.
+type GatherFunc func() ([]*dto.MetricFamily, error)
+
+// How often the settings are checked when push is disabled.
+const idleInterval = 5 * time.Second
+
+// A snappy compressed WriteRequest waiting to be sent.
+type batch struct {
+	payload []byte
+	retries int
+}
+
+// Bounded FIFO of batches waiting to be sent. When full, the oldest waiting
+// batch is dropped. The batch being sent is taken out of the queue and is
+// never dropped by push.
+type queue struct {
+	lock    sync.Mutex
+	batches []*batch
+	notify  chan struct{}
+}
+
+func newQueue() *queue {
+	return &queue{notify: make(chan struct{}, 1)}
+}
+
+func (q *queue) push(b *batch, size int) {
+	q.lock.Lock()
+	for len(q.batches) >= size {
+		q.batches = q.batches[1:]
+		selfmetrics.RemoteWriteDropped()
+		logger.Warningf("remote-write: queue full, dropping oldest batch")
+	}
+	q.batches = append(q.batches, b)
+	q.lock.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Remove and return the oldest batch, waiting for one to be queued if
+// necessary.
+func (q *queue) pop() *batch {
+	for {
+		q.lock.Lock()
+		if len(q.batches) > 0 {
+			b := q.batches[0]
+			q.batches = q.batches[1:]
+			q.lock.Unlock()
+			return b
+		}
+		q.lock.Unlock()
+		<-q.notify
+	}
+}
+
+// Return true if size batches are waiting to be sent.
+func (q *queue) full(size int) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.batches) >= size
+}
+
+// Error on which the request should be retried.
+type recoverableError struct {
+	error
+}
+
+type writer struct {
+	settings *config.RemoteWrite
+	client   *http.Client
+	queue    *queue
+}
+
+// Return the active settings. The HTTP client is recreated when the
+// configuration has been reloaded.
+func (w *writer) refresh() (*config.RemoteWrite, error) {
+	s := config.RemoteWriteSettings()
+	if s != w.settings || w.client == nil {
+		client, err := httpclient.New(&s.Tls, s.Timeout)
+		if err != nil {
+			return s, err
+		}
+		w.settings = s
+		w.client = client
+	}
+	return s, nil
+}
+
+func (w *writer) send(payload []byte) error {
+	s, err := w.refresh()
+	if err != nil {
+		return recoverableError{err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "openstack-network-exporter")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	auth, err := httpclient.Authorization(&s.Credentials)
+	if err != nil {
+		return recoverableError{err}
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// Send the queued batches in order, retrying recoverable errors with an
+// exponential backoff. A batch is given up when the queue fills up while it is
+// being retried.
+func (w *writer) sendLoop() {
+	for {
+		b := w.queue.pop()
+		backoff := config.RemoteWriteSettings().MinBackoff
+
+		for {
+			err := w.send(b.payload)
+			if err == nil {
+				selfmetrics.RemoteWriteSent()
+				break
+			}
+			s := config.RemoteWriteSettings()
+			if !errors.As(err, new(recoverableError)) {
+				logger.With("error", err).Errf("remote-write: dropping batch")
+				selfmetrics.RemoteWriteDropped()
+				break
+			}
+			b.retries++
+			if s.MaxRetries > 0 && b.retries > s.MaxRetries {
+				logger.With("error", err).Errf(
+					"remote-write: dropping batch after %d retries", s.MaxRetries)
+				selfmetrics.RemoteWriteDropped()
+				break
+			}
+			logger.With("error", err).Warningf("remote-write: retrying in %s", backoff)
+			time.Sleep(backoff)
+			backoff = min(2*backoff, s.MaxBackoff)
+			if w.queue.full(s.QueueSize) {
+				logger.With("error", err).Errf(
+					"remote-write: queue full, dropping batch after %d retries", b.retries)
+				selfmetrics.RemoteWriteDropped()
+				break
+			}
+		}
+	}
+}
+
+// Run gathers metrics at the configured interval and pushes them to the
+// remote_write receiver. Settings are read from the active configuration
+// and follow reloads. Nothing is pushed while the url is empty. Run never
+// returns.
+func Run(gather GatherFunc) {
+	w := &writer{queue: newQueue()}
+	go w.sendLoop()
+
+	for {
+		s := config.RemoteWriteSettings()
+		if s.Url == "" {
+			time.Sleep(idleInterval)
+			continue
+		}
+
+		start := time.Now()
+		families, err := gather()
+		if err != nil {
+			logger.With("error", err).Errf("remote-write: gather")
+		}
+		if len(families) > 0 {
+			payload := snappy.Encode(nil, encode(families, start))
+			w.queue.push(&batch{payload: payload}, s.QueueSize)
+		}
+
+		time.Sleep(time.Until(start.Add(s.Interval)))
+	}
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package remotewrite
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func loadConfig(t *testing.T, yaml string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENSTACK_NETWORK_EXPORTER_YAML", path)
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Iterate over the fields of a protobuf message.
+func fields(t *testing.T, b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte)) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		fn(num, typ, b[:n])
+		b = b[n:]
+	}
+}
+
+type decodedSeries struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// Decode the time series of a WriteRequest.
+func decode(t *testing.T, b []byte) []decodedSeries {
+	var res []decodedSeries
+	fields(t, b, func(num protowire.Number, _ protowire.Type, v []byte) {
+		if num != writeRequestTimeseries {
+			return
+		}
+		ts, _ := protowire.ConsumeBytes(v)
+		s := decodedSeries{labels: make(map[string]string)}
+		fields(t, ts, func(num protowire.Number, _ protowire.Type, v []byte) {
+			msg, _ := protowire.ConsumeBytes(v)
+			switch num {
+			case timeSeriesLabels:
+				var name, value string
+				fields(t, msg, func(num protowire.Number, _ protowire.Type, v []byte) {
+					str, _ := protowire.ConsumeString(v)
+					if num == labelName {
+						name = str
+					} else {
+						value = str
+					}
+				})
+				s.labels[name] = value
+			case timeSeriesSamples:
+				fields(t, msg, func(num protowire.Number, _ protowire.Type, v []byte) {
+					if num == sampleValue {
+						bits, _ := protowire.ConsumeFixed64(v)
+						s.value = math.Float64frombits(bits)
+					} else {
+						ts, _ := protowire.ConsumeVarint(v)
+						s.timestamp = int64(ts)
+					}
+				})
+			}
+		})
+		res = append(res, s)
+	})
+	return res
+}
+
+func gatherTest() []*dto.MetricFamily {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ovs_test_gauge", Help: "test",
+	}, []string{"bridge"})
+	gauge.WithLabelValues("br-int").Set(42)
+	registry.MustRegister(gauge)
+	families, err := registry.Gather()
+	if err != nil {
+		panic(err)
+	}
+	return families
+}
+
+func TestPush(t *testing.T) {
+	var received atomic.Pointer[[]decodedSeries]
+	var failures atomic.Int32
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first request to exercise the retry logic
+		if failures.Add(1) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "snappy" {
+			t.Errorf("unexpected content encoding: %q", r.Header.Get("Content-Encoding"))
+		}
+		if u, p, _ := r.BasicAuth(); u != "user" || p != "secret" {
+			t.Errorf("unexpected credentials: %q %q", u, p)
+		}
+		body, _ := io.ReadAll(r.Body)
+		buf, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Error(err)
+		}
+		series := decode(t, buf)
+		received.Store(&series)
+		close(done)
+	}))
+	defer server.Close()
+
+	loadConfig(t, `
+remote-write:
+  url: `+server.URL+`
+  min-backoff: 10ms
+  basic-auth:
+    username: user
+    password: secret
+`)
+
+	w := &writer{queue: newQueue()}
+	go w.sendLoop()
+
+	now := time.UnixMilli(1700000000000)
+	w.queue.push(&batch{payload: snappy.Encode(nil, encode(gatherTest(), now))}, 10)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received")
+	}
+
+	series := *received.Load()
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+	s := series[0]
+	if s.labels["__name__"] != "ovs_test_gauge" || s.labels["bridge"] != "br-int" {
+		t.Errorf("unexpected labels: %v", s.labels)
+	}
+	if s.value != 42 || s.timestamp != now.UnixMilli() {
+		t.Errorf("unexpected sample: %v @ %d", s.value, s.timestamp)
+	}
+	if failures.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", failures.Load())
+	}
+}
+
+func TestQueueDropsOldest(t *testing.T) {
+	q := newQueue()
+	sending := &batch{}
+	q.push(sending, 2)
+	if q.pop() != sending {
+		t.Fatal("oldest batch not returned")
+	}
+
+	oldest := &batch{}
+	q.push(oldest, 2)
+	q.push(&batch{}, 2)
+	q.push(&batch{}, 2)
+
+	if slices.Contains(q.batches, oldest) {
+		t.Error("oldest waiting batch not dropped")
+	}
+	if slices.Contains(q.batches, sending) || len(q.batches) != 2 || !q.full(2) {
+		t.Errorf("unexpected batches: %v", q.batches)
+	}
+}
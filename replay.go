@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/instance"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/replay"
+)
+
+var replayFlag = flag.String("replay", "",
+	"Read the backend outputs from a directory or a support bundle instead of\n"+
+		"the live sockets. The instances settings are ignored.")
+
+// Replace the live backends with the outputs recorded in path.
+func replayBackends(path string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	dir := path
+	if !st.IsDir() {
+		dir, err = replay.Extract(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "replay: %s extracted in %s\n", path, dir)
+	}
+	return instance.Replay(dir)
+}
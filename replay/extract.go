@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package replay
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extract a gzip compressed support bundle into a new temporary directory
+// and return its path.
+func Extract(bundle string) (string, error) {
+	f, err := os.Open(bundle)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", bundle, err)
+	}
+	defer gz.Close()
+
+	dir, err := os.MkdirTemp("", "openstack-network-exporter-replay-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := extractTar(tar.NewReader(gz), dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("%s: %w", bundle, err)
+	}
+
+	return dir, nil
+}
+
+func extractTar(r *tar.Reader, dir string) error {
+	for {
+		hdr, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeSymlink {
+			continue
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." ||
+			strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid file name: %q", hdr.Name)
+		}
+		if err := checkParents(dir, name); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			// sysfs links are only resolved with readlink, never followed
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, r)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Check that no parent directory of name in dir is a symbolic link. Files
+// would otherwise be written where the link points to.
+func checkParents(dir, name string) error {
+	parent := dir
+	for _, elem := range strings.Split(filepath.Dir(name), string(filepath.Separator)) {
+		if elem == "." {
+			break
+		}
+		parent = filepath.Join(parent, elem)
+		st, err := os.Lstat(parent)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if st.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("invalid file name: %q: symbolic link in path", name)
+		}
+	}
+	return nil
+}
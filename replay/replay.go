@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package replay implements the backends with outputs recorded in a
+// directory instead of live sockets. The directory layout is the same as in
+// support bundles:
+//
+//	<daemon>/<command>.txt             appctl command outputs
+//	ovsdb/<table>.json                 OVSDB rows in OVSDB notation
+//	openflow/<bridge>/aggregate.bin    raw OpenFlow aggregate stats reply
+//	openflow/<bridge>/router-ports.bin raw OpenFlow flow stats reply
+//	openflow/<bridge>/*.json           decoded replies, not replayed
+//	netlink/links.json                 rtnetlink interfaces
+//	sys/                               sysfs files of the SR-IOV interfaces
+//	ovs-rundir/ovs-vswitchd.pid
+//	proc/<pid>/task/<tid>/status
+//
+// The files of named instances are stored in instances/<name>/. Instance and
+// bridge names are converted with FileName. Missing files are reported as
+// backend errors.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jsimonetti/rtnetlink/v2"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/appctl"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/internal/selfmetrics"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/log"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/openflow"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+var logger = log.Module("replay")
+
+func readJSON(path string, v any) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Return path if it is an existing directory.
+func checkDir(path string) (string, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return path, err
+	}
+	if !st.IsDir() {
+		return path, fmt.Errorf("%s: not a directory", path)
+	}
+	return path, nil
+}
+
+// FileName returns name as a single path element of the recorded outputs.
+// Characters other than letters, digits, dots, dashes and underscores are
+// replaced with underscores.
+func FileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// Appctl replays the unixctl command outputs stored in <daemon>/<command>.txt.
+// Command arguments are ignored.
+type Appctl struct {
+	dir string
+}
+
+func NewAppctl(dir string) *Appctl {
+	return &Appctl{dir: dir}
+}
+
+func (a *Appctl) call(daemon, method string) (string, error) {
+	path := filepath.Join(a.dir, daemon, method+".txt")
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		selfmetrics.BackendError(selfmetrics.Unixctl)
+		return "", fmt.Errorf("%s: %w", daemon, err)
+	}
+	return string(buf), nil
+}
+
+func (a *Appctl) OvsVSwitchd(_ context.Context, method string, args ...string) (string, error) {
+	return a.call("ovs-vswitchd", method)
+}
+
+func (a *Appctl) OvnController(_ context.Context, method string, args ...string) (string, error) {
+	return a.call("ovn-controller", method)
+}
+
+func (a *Appctl) OvnNorthd(_ context.Context, method string, args ...string) (string, error) {
+	return a.call("ovn-northd", method)
+}
+
+func (a *Appctl) OvsDbServer(_ context.Context, method string, args ...string) (string, error) {
+	return a.call("ovsdb-server", method)
+}
+
+func (a *Appctl) Call(_ context.Context, target, method string, args ...string) (string, error) {
+	return a.call(appctl.TargetName(target), method)
+}
+
+func (a *Appctl) Ping(daemon string) (string, error) {
+	if !slices.Contains(appctl.Daemons(), daemon) {
+		return "", fmt.Errorf("unknown daemon: %q", daemon)
+	}
+	return checkDir(filepath.Join(a.dir, daemon))
+}
+
+// Ovsdb replays the OVSDB rows stored in ovsdb/<table>.json.
+type Ovsdb struct {
+	dir string
+}
+
+func NewOvsdb(dir string) *Ovsdb {
+	return &Ovsdb{dir: filepath.Join(dir, "ovsdb")}
+}
+
+func (o *Ovsdb) Rows(ctx context.Context, table string) ([]ovsdb.Row, error) {
+	var rows []ovsdb.Row
+	if err := readJSON(filepath.Join(o.dir, table+".json"), &rows); err != nil {
+		logger.With("error", err).Errf("Rows(%s)", table)
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (o *Ovsdb) Ping() (string, error) {
+	return checkDir(o.dir)
+}
+
+// Openflow replays the raw OpenFlow replies stored in openflow/<bridge>/.
+// They are decoded as the replies of live bridges.
+type Openflow struct {
+	dir       string
+	intBrdNam string
+}
+
+func NewOpenflow(dir, intBrdNam string) *Openflow {
+	return &Openflow{dir: filepath.Join(dir, "openflow"), intBrdNam: intBrdNam}
+}
+
+func countOpenflowError(err *error) {
+	if *err != nil {
+		selfmetrics.BackendError(selfmetrics.Openflow)
+	}
+}
+
+func (o *Openflow) Ping(bridge string) (string, error) {
+	return checkDir(filepath.Join(o.dir, FileName(bridge)))
+}
+
+func (o *Openflow) AggregateReply(_ context.Context, bridge string) (_ []byte, err error) {
+	defer countOpenflowError(&err)
+
+	return os.ReadFile(filepath.Join(o.dir, FileName(bridge), "aggregate.bin"))
+}
+
+func (o *Openflow) AggregateStats(ctx context.Context, bridge string) (_ *openflow.BridgeStats, err error) {
+	data, err := o.AggregateReply(ctx, bridge)
+	if err != nil {
+		return nil, err
+	}
+	defer countOpenflowError(&err)
+
+	return openflow.DecodeAggregateReply(bridge, data)
+}
+
+func (o *Openflow) RouterPortsReply(context.Context) (_ []byte, err error) {
+	defer countOpenflowError(&err)
+
+	return os.ReadFile(filepath.Join(o.dir, FileName(o.intBrdNam), "router-ports.bin"))
+}
+
+func (o *Openflow) RouterPortsStats(ctx context.Context) (_ []openflow.RouterPortsStats, err error) {
+	data, err := o.RouterPortsReply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer countOpenflowError(&err)
+
+	return openflow.DecodeRouterPortsReply(data)
+}
+
+// Netlink replays the interfaces stored in netlink/links.json. Their sysfs
+// attributes are read from the sys/ directory.
+type Netlink struct {
+	dir string
+}
+
+func NewNetlink(dir string) *Netlink {
+	return &Netlink{dir: dir}
+}
+
+func (n *Netlink) Links() ([]rtnetlink.LinkMessage, error) {
+	var links []rtnetlink.LinkMessage
+	if err := readJSON(filepath.Join(n.dir, "netlink", "links.json"), &links); err != nil {
+		selfmetrics.BackendError(selfmetrics.Netlink)
+		return nil, err
+	}
+	return links, nil
+}
+
+func (n *Netlink) SysfsRoot() string {
+	return filepath.Join(n.dir, "sys")
+}
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package replay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb"
+	"github.com/openstack-k8s-operators/openstack-network-exporter/ovsdb/ovs"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppctl(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ovs-vswitchd", "coverage", "show.txt"), "total: 1\n")
+
+	a := NewAppctl(dir)
+	reply, err := a.OvsVSwitchd(context.Background(), "coverage/show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "total: 1\n" {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+	if _, err := a.OvnController(context.Background(), "coverage/show"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := a.Ping("ovs-vswitchd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Ping("ovn-northd"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestOvsdb(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ovsdb", "Bridge.json"), `[
+		{"_uuid": ["uuid", "8f4bd5d8-6bd2-4b7a-8a3d-5f0e8b8f9f10"],
+		 "name": "br-int", "datapath_type": "netdev"},
+		{"_uuid": ["uuid", "2a1c3c8e-0c59-4d6b-9d7e-13d9b2c0f1a2"],
+		 "name": "br-ex", "datapath_type": "system"}
+	]`)
+
+	var bridges []ovs.Bridge
+	if err := ovsdb.List(context.Background(), NewOvsdb(dir), &bridges); err != nil {
+		t.Fatal(err)
+	}
+	if len(bridges) != 2 || bridges[0].Name != "br-int" || bridges[1].DatapathType != "system" {
+		t.Fatalf("unexpected bridges: %+v", bridges)
+	}
+	if bridges[0].UUID != "8f4bd5d8-6bd2-4b7a-8a3d-5f0e8b8f9f10" {
+		t.Fatalf("unexpected uuid: %s", bridges[0].UUID)
+	}
+
+	var vswitch ovs.OpenvSwitch
+	if err := ovsdb.Get(context.Background(), NewOvsdb(dir), &vswitch); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func bundle(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0o644, Size: int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	writeFile(t, path, buf.String())
+	return path
+}
+
+func TestExtract(t *testing.T) {
+	path := bundle(t, map[string]string{
+		"version.txt":                  "v1\n",
+		"ovs-vswitchd/memory/show.txt": "handlers:1\n",
+	})
+
+	dir, err := Extract(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if reply, _ := NewAppctl(dir).OvsVSwitchd(context.Background(), "memory/show"); reply != "handlers:1\n" {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestExtractInvalidName(t *testing.T) {
+	path := bundle(t, map[string]string{"../escape.txt": "x"})
+
+	if dir, err := Extract(path); err == nil {
+		os.RemoveAll(dir)
+		t.Fatal("expected error")
+	}
+}
+
+func TestExtractSymlinkParent(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	outside := t.TempDir()
+	if err := tw.WriteHeader(&tar.Header{Name: "sys", Typeflag: tar.TypeSymlink, Linkname: outside}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "sys/escape.txt", Typeflag: tar.TypeReg, Mode: 0o644}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	writeFile(t, path, buf.String())
+
+	if dir, err := Extract(path); err == nil {
+		os.RemoveAll(dir)
+		t.Fatal("expected error")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "escape.txt")); err == nil {
+		t.Fatal("file written outside of the bundle directory")
+	}
+}
+
+func TestFileName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"br-int":    "br-int",
+		"edpm_0.1":  "edpm_0.1",
+		"../../etc": ".._.._etc",
+		"..":        "_",
+		"":          "_",
+		"a/b c":     "a_b_c",
+	} {
+		if got := FileName(name); got != expected {
+			t.Errorf("%q: got %q, expected %q", name, got, expected)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/openstack-k8s-operators/openstack-network-exporter/bundle"
+)
+
+var supportBundleFlag = flag.String("support-bundle", "",
+	"Write a gzip compressed tarball with the raw outputs of all backends, the\n"+
+		"effective configuration and the exporter version to the specified file\n"+
+		"and exit. Use \"-\" to write to stdout.")
+
+// Write the support bundle. Errors are printed on stderr. Return the process
+// exit status.
+func writeSupportBundle(path string) int {
+	var out io.WriteCloser = os.Stdout
+	if path != "-" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return 1
+		}
+		out = f
+	}
+
+	err := bundle.Write(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}